@@ -0,0 +1,352 @@
+// Package archive is the container format used for directory and glob
+// inputs. Sources generated from such inputs import it to serve the packed
+// files as an fs.FS.
+package archive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+const magic = "CEAR"
+
+var (
+	ErrFormat   = errors.New("archive: invalid format")
+	ErrChecksum = errors.New("archive: checksum mismatch")
+	ErrCodec    = errors.New("archive: no decompressor for entry codec")
+)
+
+type Entry struct {
+	Path    string
+	Mode    fs.FileMode
+	ModTime time.Time
+	Codec   string
+	Block   int
+	Offset  int64
+	Length  int64
+	Size    int64
+	Sum     [sha256.Size]byte
+}
+
+type Block struct {
+	Codec  string
+	Offset int64
+	Length int64
+	Size   int64
+}
+
+type Writer struct {
+	Codec     string
+	Compress  func(data []byte) ([]byte, error)
+	BlockSize int
+	entries   []Entry
+	blocks    []Block
+	pending   []byte
+	data      []byte
+	sums      map[[sha256.Size]byte]Entry
+	dupFiles  int
+	dupBytes  int64
+}
+
+func (w *Writer) Deduplicated() (files int, bytes int64) {
+	return w.dupFiles, w.dupBytes
+}
+
+func (w *Writer) Entries() []Entry {
+	return w.entries
+}
+
+func (w *Writer) compress(data []byte) (string, []byte, error) {
+	if w.Compress == nil || len(data) == 0 {
+		return "", data, nil
+	}
+	compressed, err := w.Compress(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(compressed) >= len(data) {
+		return "", data, nil
+	}
+	return w.Codec, compressed, nil
+}
+
+func (w *Writer) flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	codec, data, err := w.compress(w.pending)
+	if err != nil {
+		return err
+	}
+	w.blocks = append(w.blocks, Block{
+		Codec:  codec,
+		Offset: int64(len(w.data)),
+		Length: int64(len(data)),
+		Size:   int64(len(w.pending)),
+	})
+	w.data = append(w.data, data...)
+	w.pending = nil
+	return nil
+}
+
+func (w *Writer) Add(name string, mode fs.FileMode, modTime time.Time, data []byte) error {
+	e := Entry{
+		Path:    name,
+		Mode:    mode,
+		ModTime: modTime,
+		Block:   -1,
+		Size:    int64(len(data)),
+		Length:  int64(len(data)),
+	}
+	if !mode.IsDir() {
+		e.Sum = sha256.Sum256(data)
+	}
+	if prev, ok := w.sums[e.Sum]; ok && len(data) > 0 && prev.Size == e.Size {
+		e.Codec, e.Block, e.Offset, e.Length = prev.Codec, prev.Block, prev.Offset, prev.Length
+		w.entries = append(w.entries, e)
+		w.dupFiles++
+		w.dupBytes += e.Size
+		return nil
+	}
+	if w.sums == nil {
+		w.sums = map[[sha256.Size]byte]Entry{}
+	}
+	if w.BlockSize > 0 && len(data) > 0 {
+		e.Block = len(w.blocks)
+		e.Offset = int64(len(w.pending))
+		w.entries = append(w.entries, e)
+		w.sums[e.Sum] = e
+		w.pending = append(w.pending, data...)
+		if len(w.pending) >= w.BlockSize {
+			return w.flush()
+		}
+		return nil
+	}
+	codec, data, err := w.compress(data)
+	if err != nil {
+		return err
+	}
+	e.Codec = codec
+	e.Offset = int64(len(w.data))
+	e.Length = int64(len(data))
+	w.entries = append(w.entries, e)
+	if !mode.IsDir() {
+		w.sums[e.Sum] = e
+	}
+	w.data = append(w.data, data...)
+	return nil
+}
+
+func (w *Writer) Bytes() ([]byte, error) {
+	if err := w.flush(); err != nil {
+		return nil, err
+	}
+	header := binary.AppendUvarint(nil, uint64(len(w.blocks)))
+	for _, b := range w.blocks {
+		header = binary.AppendUvarint(header, uint64(len(b.Codec)))
+		header = append(header, b.Codec...)
+		header = binary.AppendUvarint(header, uint64(b.Offset))
+		header = binary.AppendUvarint(header, uint64(b.Length))
+		header = binary.AppendUvarint(header, uint64(b.Size))
+	}
+	header = binary.AppendUvarint(header, uint64(len(w.entries)))
+	for _, e := range w.entries {
+		header = binary.AppendUvarint(header, uint64(len(e.Path)))
+		header = append(header, e.Path...)
+		header = binary.AppendUvarint(header, uint64(e.Mode))
+		header = binary.AppendVarint(header, e.ModTime.Unix())
+		header = binary.AppendUvarint(header, uint64(e.ModTime.Nanosecond()))
+		header = binary.AppendUvarint(header, uint64(len(e.Codec)))
+		header = append(header, e.Codec...)
+		header = binary.AppendUvarint(header, uint64(e.Block+1))
+		header = binary.AppendUvarint(header, uint64(e.Offset))
+		header = binary.AppendUvarint(header, uint64(e.Length))
+		header = binary.AppendUvarint(header, uint64(e.Size))
+		header = append(header, e.Sum[:]...)
+	}
+	out := make([]byte, 0, len(magic)+4+len(header)+len(w.data))
+	out = append(out, magic...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(header)))
+	out = append(out, header...)
+	return append(out, w.data...), nil
+}
+
+type Reader struct {
+	Decompress func(codec string, data []byte) ([]byte, error)
+	Blocks     []Block
+	Entries    []Entry
+	r          io.ReaderAt
+	base       int64
+	mu         sync.Mutex
+	cached     int
+	cache      []byte
+}
+
+func NewBytesReader(data []byte) (*Reader, error) {
+	return NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	var prefix [len(magic) + 4]byte
+	if _, err := r.ReadAt(prefix[:], 0); err != nil {
+		return nil, ErrFormat
+	}
+	if string(prefix[:len(magic)]) != magic {
+		return nil, ErrFormat
+	}
+	n := int64(binary.LittleEndian.Uint32(prefix[len(magic):]))
+	base := int64(len(prefix)) + n
+	if base > size {
+		return nil, ErrFormat
+	}
+	header := make([]byte, n)
+	if _, err := r.ReadAt(header, int64(len(prefix))); err != nil {
+		return nil, ErrFormat
+	}
+
+	h := headerReader{buf: header}
+	count := h.uvarint()
+	if count > uint64(len(header)) {
+		return nil, ErrFormat
+	}
+	blocks := make([]Block, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var b Block
+		b.Codec = string(h.bytes(h.uvarint()))
+		b.Offset = int64(h.uvarint())
+		b.Length = int64(h.uvarint())
+		b.Size = int64(h.uvarint())
+		if h.err != nil || b.Offset < 0 || b.Length < 0 || b.Size < 0 || b.Length > size-base || b.Offset > size-base-b.Length {
+			return nil, ErrFormat
+		}
+		blocks = append(blocks, b)
+	}
+	count = h.uvarint()
+	if count > uint64(len(header)) {
+		return nil, ErrFormat
+	}
+	entries := make([]Entry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var e Entry
+		e.Path = string(h.bytes(h.uvarint()))
+		e.Mode = fs.FileMode(h.uvarint())
+		sec := h.varint()
+		e.ModTime = time.Unix(sec, int64(h.uvarint()))
+		e.Codec = string(h.bytes(h.uvarint()))
+		e.Block = int(h.uvarint()) - 1
+		e.Offset = int64(h.uvarint())
+		e.Length = int64(h.uvarint())
+		e.Size = int64(h.uvarint())
+		copy(e.Sum[:], h.bytes(sha256.Size))
+		limit := size - base
+		if e.Block >= len(blocks) {
+			return nil, ErrFormat
+		} else if e.Block >= 0 {
+			limit = blocks[e.Block].Size
+		}
+		if h.err != nil || e.Offset < 0 || e.Length < 0 || e.Size < 0 || e.Length > limit || e.Offset > limit-e.Length {
+			return nil, ErrFormat
+		}
+		entries = append(entries, e)
+	}
+	if h.err != nil || len(h.buf) != 0 {
+		return nil, ErrFormat
+	}
+	return &Reader{Blocks: blocks, Entries: entries, r: r, base: base, cached: -1}, nil
+}
+
+func (r *Reader) read(codec string, offset, length, size int64) ([]byte, error) {
+	data := make([]byte, length)
+	if _, err := r.r.ReadAt(data, r.base+offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if codec != "" {
+		if r.Decompress == nil {
+			return nil, ErrCodec
+		}
+		var err error
+		if data, err = r.Decompress(codec, data); err != nil {
+			return nil, err
+		}
+	}
+	if int64(len(data)) != size {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+func (r *Reader) block(i int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cached != i {
+		b := r.Blocks[i]
+		data, err := r.read(b.Codec, b.Offset, b.Length, b.Size)
+		if err != nil {
+			return nil, err
+		}
+		r.cached, r.cache = i, data
+	}
+	return r.cache, nil
+}
+
+func (r *Reader) ReadFile(e Entry) ([]byte, error) {
+	var data []byte
+	var err error
+	if e.Block >= 0 {
+		var block []byte
+		if block, err = r.block(e.Block); err != nil {
+			return nil, err
+		}
+		data = append([]byte(nil), block[e.Offset:e.Offset+e.Length]...)
+	} else if data, err = r.read(e.Codec, e.Offset, e.Length, e.Size); err != nil {
+		return nil, err
+	}
+	if !e.Mode.IsDir() && (int64(len(data)) != e.Size || sha256.Sum256(data) != e.Sum) {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+type headerReader struct {
+	buf []byte
+	err error
+}
+
+func (h *headerReader) uvarint() uint64 {
+	v, n := binary.Uvarint(h.buf)
+	if n <= 0 {
+		h.err = ErrFormat
+		h.buf = nil
+		return 0
+	}
+	h.buf = h.buf[n:]
+	return v
+}
+
+func (h *headerReader) varint() int64 {
+	v, n := binary.Varint(h.buf)
+	if n <= 0 {
+		h.err = ErrFormat
+		h.buf = nil
+		return 0
+	}
+	h.buf = h.buf[n:]
+	return v
+}
+
+func (h *headerReader) bytes(n uint64) []byte {
+	if n > uint64(len(h.buf)) {
+		h.err = ErrFormat
+		h.buf = nil
+		return nil
+	}
+	b := h.buf[:n]
+	h.buf = h.buf[n:]
+	return b
+}
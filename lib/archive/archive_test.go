@@ -0,0 +1,216 @@
+package archive
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var files = map[string]string{
+	"index.html":       strings.Repeat("<p>hello</p>\n", 100),
+	"css/site.css":     strings.Repeat("body { margin: 0 }\n", 50),
+	"css/copy.css":     strings.Repeat("body { margin: 0 }\n", 50),
+	"js/app.js":        "console.log(1)\n",
+	"js/vendor/lib.js": strings.Repeat("var x = 1;\n", 300),
+	"empty.txt":        "",
+}
+
+func compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, _ := flate.NewWriter(&b, flate.BestCompression)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func decompress(codec string, data []byte) ([]byte, error) {
+	if codec != "flate" {
+		return nil, ErrCodec
+	}
+	return io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+}
+
+func pack(t *testing.T, w *Writer) []byte {
+	t.Helper()
+	mod := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	if err := w.Add("css", fs.ModeDir|0o755, mod, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "css/site.css", "css/copy.css", "js/app.js", "js/vendor/lib.js", "empty.txt"} {
+		if err := w.Add(name, 0o644, mod, []byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := w.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writers() map[string]*Writer {
+	return map[string]*Writer{
+		"stored":     {},
+		"compressed": {Codec: "flate", Compress: compress},
+		"solid":      {Codec: "flate", Compress: compress, BlockSize: 1 << 10},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, w := range writers() {
+		r, err := NewBytesReader(pack(t, w))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		r.Decompress = decompress
+		fsys := NewFS(r)
+		for path, want := range files {
+			got, err := fsys.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if string(got) != want {
+				t.Fatalf("%s: %s: content mismatch", name, path)
+			}
+		}
+		info, err := fsys.Stat("css/site.css")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC); !info.ModTime().Equal(want) {
+			t.Fatalf("%s: mod time %v, want %v", name, info.ModTime(), want)
+		}
+		if dup, size := w.Deduplicated(); dup != 1 || size != int64(len(files["css/copy.css"])) {
+			t.Fatalf("%s: deduplicated %d files, %d bytes", name, dup, size)
+		}
+	}
+}
+
+func TestFS(t *testing.T) {
+	for name, w := range writers() {
+		r, err := NewBytesReader(pack(t, w))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Decompress = decompress
+		if err := fstest.TestFS(NewFS(r), "index.html", "css/site.css", "js/vendor/lib.js", "empty.txt"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestMissingCodec(t *testing.T) {
+	r, err := NewBytesReader(pack(t, &Writer{Codec: "flate", Compress: compress}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFS(r).ReadFile("index.html"); !errors.Is(err, ErrCodec) {
+		t.Fatalf("got %v, want %v", err, ErrCodec)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	data := pack(t, &Writer{})
+	i := bytes.Index(data, []byte("console.log"))
+	data[i] ^= 1
+	r, err := NewBytesReader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFS(r).ReadFile("js/app.js"); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("got %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	data := pack(t, &Writer{Codec: "flate", Compress: compress, BlockSize: 1 << 10})
+	for _, bad := range [][]byte{nil, []byte("CEAR"), []byte("XXXX\x00\x00\x00\x00"), data[:len(data)/2]} {
+		if _, err := NewBytesReader(bad); err != ErrFormat {
+			t.Fatalf("got %v, want %v", err, ErrFormat)
+		}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		mut := append([]byte(nil), data...)
+		for j := 0; j < 1+rnd.Intn(4); j++ {
+			mut[rnd.Intn(len(mut))] = byte(rnd.Intn(256))
+		}
+		r, err := NewBytesReader(mut)
+		if err != nil {
+			continue
+		}
+		r.Decompress = decompress
+		for _, e := range r.Entries {
+			r.ReadFile(e)
+		}
+	}
+}
+
+func TestPack(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, "site", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := Pack(filepath.Join(dir, "site"), &Writer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewBytesReader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(NewFS(r), "index.html", "css/copy.css", "js/vendor/lib.js"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = Pack(filepath.Join(dir, "site", "*", "*.css"), &Writer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err = NewBytesReader(data); err != nil {
+		t.Fatal(err)
+	}
+	fsys := NewFS(r)
+	if err := fstest.TestFS(fsys, "css/site.css", "css/copy.css"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Stat("index.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("glob packed unmatched file: %v", err)
+	}
+
+	if _, err := Pack(filepath.Join(dir, "*.none"), &Writer{}); err == nil {
+		t.Fatal("empty glob packed without error")
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	for _, w := range writers() {
+		w.Add("a.txt", 0o644, time.Time{}, []byte(files["index.html"]))
+		w.entries[0].Offset, w.entries[0].Length = 1<<62, 1<<62
+		data, err := w.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewBytesReader(data); err != ErrFormat {
+			t.Fatalf("got %v, want %v", err, ErrFormat)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+type FS struct {
+	r     *Reader
+	nodes map[string]*node
+}
+
+type node struct {
+	entry    Entry
+	children []fs.DirEntry
+}
+
+func NewFS(r *Reader) *FS {
+	f := &FS{r: r, nodes: map[string]*node{
+		".": {entry: Entry{Path: ".", Mode: fs.ModeDir | 0o555}},
+	}}
+	for _, e := range r.Entries {
+		if !fs.ValidPath(e.Path) || e.Path == "." {
+			continue
+		}
+		if n, ok := f.nodes[e.Path]; ok {
+			n.entry = e
+			continue
+		}
+		f.nodes[e.Path] = &node{entry: e}
+		f.link(e.Path)
+	}
+	for _, n := range f.nodes {
+		sort.Slice(n.children, func(i, j int) bool { return n.children[i].Name() < n.children[j].Name() })
+	}
+	return f
+}
+
+func (f *FS) link(name string) {
+	dir := path.Dir(name)
+	parent, ok := f.nodes[dir]
+	if !ok {
+		parent = &node{entry: Entry{Path: dir, Mode: fs.ModeDir | 0o555}}
+		f.nodes[dir] = parent
+		f.link(dir)
+	}
+	parent.children = append(parent.children, fileInfo{f.nodes[name]})
+}
+
+func (f *FS) lookup(op, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n, ok := f.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	n, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.entry.Mode.IsDir() {
+		return &openDir{node: n}, nil
+	}
+	data, err := f.r.ReadFile(n.entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &openFile{node: n, Reader: bytes.NewReader(data)}, nil
+}
+
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	n, err := f.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.entry.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	data, err := f.r.ReadFile(n.entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.entry.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]fs.DirEntry(nil), n.children...), nil
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{n}, nil
+}
+
+type fileInfo struct {
+	n *node
+}
+
+func (i fileInfo) Name() string               { return path.Base(i.n.entry.Path) }
+func (i fileInfo) Size() int64                { return i.n.entry.Size }
+func (i fileInfo) Mode() fs.FileMode          { return i.n.entry.Mode }
+func (i fileInfo) ModTime() time.Time         { return i.n.entry.ModTime }
+func (i fileInfo) IsDir() bool                { return i.n.entry.Mode.IsDir() }
+func (i fileInfo) Sys() any                   { return nil }
+func (i fileInfo) Type() fs.FileMode          { return i.n.entry.Mode.Type() }
+func (i fileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+type openFile struct {
+	node *node
+	*bytes.Reader
+}
+
+func (f *openFile) Stat() (fs.FileInfo, error) { return fileInfo{f.node}, nil }
+
+func (f *openFile) Close() error { return nil }
+
+type openDir struct {
+	node   *node
+	offset int
+}
+
+func (d *openDir) Stat() (fs.FileInfo, error) { return fileInfo{d.node}, nil }
+
+func (d *openDir) Close() error { return nil }
+
+func (d *openDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.entry.Path, Err: fs.ErrInvalid}
+}
+
+func (d *openDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.node.children[d.offset:]
+	if count > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(rest) {
+		rest = rest[:count]
+	}
+	d.offset += len(rest)
+	return append([]fs.DirEntry(nil), rest...), nil
+}
@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func globRoot(pattern string) string {
+	root := filepath.Dir(pattern)
+	for strings.ContainsAny(root, "*?[") {
+		root = filepath.Dir(root)
+	}
+	return root
+}
+
+func Pack(in string, w *Writer) ([]byte, error) {
+	root, matches := in, []string{in}
+	if strings.ContainsAny(in, "*?[") {
+		var err error
+		if matches, err = filepath.Glob(in); err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", in)
+		}
+		root = globRoot(in)
+	}
+
+	seen := map[string]bool{}
+	for _, match := range matches {
+		err := filepath.WalkDir(match, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if rel == "." || seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			switch {
+			case info.IsDir():
+				return w.Add(rel, info.Mode(), info.ModTime(), nil)
+			case info.Mode().IsRegular():
+				data, err := os.ReadFile(p)
+				if err != nil {
+					return err
+				}
+				return w.Add(rel, info.Mode(), info.ModTime(), data)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return w.Bytes()
+}
@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const AutoCodec = "auto"
+
+const (
+	PolicySmallest = "smallest"
+	PolicyFastest  = "fastest"
+	PolicyWeighted = "weighted"
+)
+
+var Policies = []string{PolicySmallest, PolicyFastest, PolicyWeighted}
+
+// Scores within 2% of the best are ties, broken on size so that timing noise
+// does not flip the selection between otherwise equal candidates.
+const scoreTolerance = 0.02
+
+type Candidate struct {
+	Codec  string
+	Level  int
+	Data   []byte
+	Decode time.Duration
+	Score  float64
+}
+
+func measure(c Codec, compressed, data, dict []byte) (time.Duration, error) {
+	var best, total time.Duration
+	for i := 0; i < 10 && (i < 3 || total < 100*time.Millisecond); i++ {
+		start := time.Now()
+		out, err := c.Decompress(compressed, dict)
+		elapsed := time.Since(start)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 && !bytes.Equal(out, data) {
+			return 0, fmt.Errorf("%s: round trip mismatch", c.Name())
+		}
+		if i == 0 || elapsed < best {
+			best = elapsed
+		}
+		total += elapsed
+	}
+	return best, nil
+}
+
+func ratio(compressed, size int) float64 {
+	if size == 0 {
+		return 0
+	}
+	return 100 * float64(compressed) / float64(size)
+}
+
+func score(policy string, c, smallest, fastest Candidate) (float64, error) {
+	size := float64(len(c.Data)) / float64(len(smallest.Data))
+	speed := float64(c.Decode+1) / float64(fastest.Decode+1)
+	switch policy {
+	case PolicySmallest:
+		return size, nil
+	case PolicyFastest:
+		return speed, nil
+	case PolicyWeighted:
+		return (size + speed) / 2, nil
+	}
+	return 0, fmt.Errorf("unknown policy %q", policy)
+}
+
+func SelectCodec(data, key []byte, presetDict bool, level int, policy string, w io.Writer) (Candidate, error) {
+	var list []Candidate
+	for _, name := range CodecNames() {
+		c := codecs[name]
+		if presetDict && !c.Dictionary() {
+			continue
+		}
+		levels := []int{level}
+		if level == DefaultLevel {
+			levels = levels[:0]
+			for _, l := range c.Levels() {
+				if l > NoCompression {
+					levels = append(levels, l)
+				}
+			}
+		} else if _, err := ResolveLevel(c, level); err != nil {
+			continue
+		}
+		for _, l := range levels {
+			out, err := c.Compress(data, key, l)
+			if err != nil {
+				return Candidate{}, err
+			}
+			d, err := measure(c, out, data, key)
+			if err != nil {
+				return Candidate{}, err
+			}
+			list = append(list, Candidate{Codec: name, Level: l, Data: out, Decode: d})
+		}
+	}
+	if len(list) == 0 {
+		return Candidate{}, fmt.Errorf("no codec supports level %s", LevelName(level))
+	}
+	smallest, fastest := list[0], list[0]
+	for _, c := range list {
+		if len(c.Data) < len(smallest.Data) {
+			smallest = c
+		}
+		if c.Decode < fastest.Decode {
+			fastest = c
+		}
+	}
+	best := -1
+	for i := range list {
+		s, err := score(policy, list[i], smallest, fastest)
+		if err != nil {
+			return Candidate{}, err
+		}
+		list[i].Score = s
+		if best < 0 || s < list[best].Score {
+			best = i
+		}
+	}
+	limit := list[best].Score * (1 + scoreTolerance)
+	for i, c := range list {
+		if c.Score <= limit && (len(c.Data) < len(list[best].Data) || len(c.Data) == len(list[best].Data) && i < best) {
+			best = i
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CODEC\tLEVEL\tSIZE\tRATIO\tDECODE\tSCORE\t")
+	for i, c := range list {
+		mark := ""
+		if i == best {
+			mark = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f%%\t%s\t%.3f\t%s\n", c.Codec, LevelName(c.Level), len(c.Data),
+			ratio(len(c.Data), len(data)), c.Decode, c.Score, mark)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "Selected %s level %s (policy %s)\n", list[best].Codec, LevelName(list[best].Level), policy)
+	return list[best], nil
+}
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSelectCodec(t *testing.T) {
+	data := []byte(strings.Repeat("compressembed packs resources into Go sources\n", 100))
+	best, err := SelectCodec(data, nil, false, DefaultLevel, PolicySmallest, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range CodecNames() {
+		c := codecs[name]
+		out, err := c.Compress(data, nil, c.DefaultLevel())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) < len(best.Data) {
+			t.Errorf("selected %s with %d bytes, %s has %d", best.Codec, len(best.Data), name, len(out))
+		}
+	}
+	out, err := Decompress(best.Codec, best.Data, nil)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Fatalf("%s: round trip failed: %v", best.Codec, err)
+	}
+
+	dict := []byte("packs resources into Go sources")
+	best, err = SelectCodec(data, dict, true, DefaultLevel, PolicyFastest, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !codecs[best.Codec].Dictionary() {
+		t.Errorf("selected %s without dictionary support", best.Codec)
+	}
+
+	best, err = SelectCodec(data, nil, false, HuffmanOnly, PolicyWeighted, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveLevel(codecs[best.Codec], HuffmanOnly); err != nil || best.Level != HuffmanOnly {
+		t.Errorf("selected %s level %s for huffman", best.Codec, LevelName(best.Level))
+	}
+
+	if _, err := SelectCodec(data, nil, false, 100, PolicySmallest, io.Discard); err == nil {
+		t.Error("selected a codec for level 100")
+	}
+	if _, err := SelectCodec(data, nil, false, DefaultLevel, "tiny", io.Discard); err == nil {
+		t.Error("accepted an unknown policy")
+	}
+}
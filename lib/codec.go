@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/lecuong04/compressembed/lib/zstd"
+)
+
+type Codec interface {
+	Name() string
+	Levels() []int
+	DefaultLevel() int
+	Compress(data, dict []byte, level int) ([]byte, error)
+	Decompress(data, dict []byte) ([]byte, error)
+	NewReader(r io.Reader, dict []byte) (io.ReadCloser, error)
+	Dictionary() bool
+	Imports() []string
+	Decoder() string
+}
+
+var codecs = map[string]Codec{}
+
+var flateLevels = append([]int{flate.HuffmanOnly}, levelRange(flate.NoCompression, flate.BestCompression)...)
+
+func RegisterCodec(c Codec) {
+	codecs[c.Name()] = c
+}
+
+func GetCodec(name string) (Codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+	return c, nil
+}
+
+func CodecNames() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterCodec(zlibCodec{})
+	RegisterCodec(gzipCodec{})
+	RegisterCodec(flateCodec{})
+	RegisterCodec(zstdCodec{})
+	RegisterCodec(lz4Codec{})
+}
+
+type zlibCodec struct{}
+
+func (zlibCodec) Name() string { return "zlib" }
+
+func (zlibCodec) Levels() []int { return flateLevels }
+
+func (zlibCodec) DefaultLevel() int { return flate.BestCompression }
+
+func (zlibCodec) Compress(data, dict []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := zlib.NewWriterLevelDict(&buf, level, dict)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (zlibCodec) Decompress(data, dict []byte) ([]byte, error) {
+	r, err := zlib.NewReaderDict(bytes.NewReader(data), dict)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (zlibCodec) NewReader(r io.Reader, dict []byte) (io.ReadCloser, error) {
+	return zlib.NewReaderDict(r, dict)
+}
+
+func (zlibCodec) Dictionary() bool { return true }
+
+func (zlibCodec) Imports() []string { return []string{"compress/zlib"} }
+
+func (zlibCodec) Decoder() string { return "return zlib.NewReaderDict(r, dict)" }
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (gzipCodec) Levels() []int { return flateLevels }
+
+func (gzipCodec) DefaultLevel() int { return flate.BestCompression }
+
+func (gzipCodec) Compress(data, dict []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(data, dict []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (gzipCodec) NewReader(r io.Reader, dict []byte) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCodec) Dictionary() bool { return false }
+
+func (gzipCodec) Imports() []string { return []string{"compress/gzip"} }
+
+func (gzipCodec) Decoder() string { return "return gzip.NewReader(r)" }
+
+type flateCodec struct{}
+
+func (flateCodec) Name() string { return "flate" }
+
+func (flateCodec) Levels() []int { return flateLevels }
+
+func (flateCodec) DefaultLevel() int { return flate.BestCompression }
+
+func (flateCodec) Compress(data, dict []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, level, dict)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCodec) Decompress(data, dict []byte) ([]byte, error) {
+	r := flate.NewReaderDict(bytes.NewReader(data), dict)
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (flateCodec) NewReader(r io.Reader, dict []byte) (io.ReadCloser, error) {
+	return flate.NewReaderDict(r, dict), nil
+}
+
+func (flateCodec) Dictionary() bool { return true }
+
+func (flateCodec) Imports() []string { return []string{"compress/flate"} }
+
+func (flateCodec) Decoder() string { return "return flate.NewReaderDict(r, dict), nil" }
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string { return "zstd" }
+
+func (zstdCodec) Levels() []int {
+	return append([]int{zstd.HuffmanOnly, zstd.NoCompression}, levelRange(zstd.MinLevel, zstd.MaxLevel)...)
+}
+
+func (zstdCodec) DefaultLevel() int { return zstd.DefaultLevel }
+
+func (zstdCodec) Compress(data, dict []byte, level int) ([]byte, error) {
+	return zstd.Encode(data, dict, level), nil
+}
+
+func (zstdCodec) Decompress(data, dict []byte) ([]byte, error) {
+	return zstd.Decode(data, dict)
+}
+
+func (zstdCodec) NewReader(r io.Reader, dict []byte) (io.ReadCloser, error) {
+	return zstd.NewReaderDict(r, dict)
+}
+
+func (zstdCodec) Dictionary() bool { return true }
+
+func (zstdCodec) Imports() []string {
+	return []string{"github.com/lecuong04/compressembed/lib/zstd"}
+}
+
+func (zstdCodec) Decoder() string { return "return zstd.NewReaderDict(r, dict)" }
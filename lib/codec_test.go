@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func testData() map[string][]byte {
+	random := make([]byte, 32<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+	return map[string][]byte{
+		"empty":  {},
+		"byte":   {'x'},
+		"zeros":  make([]byte, 100<<10),
+		"random": random,
+		"text":   []byte(strings.Repeat("compressembed packs resources into Go sources\n", 2000)),
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	dict := []byte(strings.Repeat("resources into Go sources\n", 10))
+	for _, name := range CodecNames() {
+		c, err := GetCodec(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dicts := [][]byte{nil}
+		if c.Dictionary() {
+			dicts = append(dicts, dict)
+		}
+		for _, level := range c.Levels() {
+			for _, d := range dicts {
+				for input, data := range testData() {
+					enc, err := c.Compress(data, d, level)
+					if err != nil {
+						t.Fatalf("%s level %d %s: %v", name, level, input, err)
+					}
+					dec, err := c.Decompress(enc, d)
+					if err != nil {
+						t.Fatalf("%s level %d %s: %v", name, level, input, err)
+					}
+					if !bytes.Equal(dec, data) {
+						t.Fatalf("%s level %d %s: Decompress mismatch", name, level, input)
+					}
+					r, err := c.NewReader(bytes.NewReader(enc), d)
+					if err != nil {
+						t.Fatalf("%s level %d %s: %v", name, level, input, err)
+					}
+					dec, err = io.ReadAll(r)
+					r.Close()
+					if err != nil {
+						t.Fatalf("%s level %d %s: %v", name, level, input, err)
+					}
+					if !bytes.Equal(dec, data) {
+						t.Fatalf("%s level %d %s: NewReader mismatch", name, level, input)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCodecCorrupt(t *testing.T) {
+	data := testData()["text"]
+	for _, name := range CodecNames() {
+		c, _ := GetCodec(name)
+		enc, err := c.Compress(data, nil, c.DefaultLevel())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Decompress(enc[:len(enc)/2], nil); err == nil {
+			t.Errorf("%s: truncated input decoded without error", name)
+		}
+		if _, err := c.Decompress([]byte{1, 2, 3}, nil); err == nil {
+			t.Errorf("%s: garbage decoded without error", name)
+		}
+	}
+}
+
+func TestGetCodec(t *testing.T) {
+	if _, err := GetCodec("nope"); err == nil {
+		t.Fatal("unknown codec accepted")
+	}
+}
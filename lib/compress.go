@@ -1,30 +1,31 @@
 package lib
 
-import (
-	"bytes"
-	"compress/flate"
-	"compress/zlib"
-	"io"
-)
+import "io"
 
-func Compress(data, key []byte) []byte {
-	var buf bytes.Buffer
-	w, err := zlib.NewWriterLevelDict(&buf, flate.BestCompression, key)
+func Compress(codec string, data, key []byte, level int) ([]byte, error) {
+	c, err := GetCodec(codec)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	_, _ = w.Write(data)
-	w.Close()
-	return buf.Bytes()
+	level, err = ResolveLevel(c, level)
+	if err != nil {
+		return nil, err
+	}
+	return c.Compress(data, key, level)
+}
+
+func Decompress(codec string, data, key []byte) ([]byte, error) {
+	c, err := GetCodec(codec)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decompress(data, key)
 }
 
-func Decompress(data, key []byte) []byte {
-	var buf bytes.Buffer
-	r, err := zlib.NewReaderDict(bytes.NewReader(data), key)
+func DecompressReader(codec string, r io.Reader, key []byte) (io.ReadCloser, error) {
+	c, err := GetCodec(codec)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	_, _ = io.Copy(&buf, r)
-	r.Close()
-	return buf.Bytes()
+	return c.NewReader(r, key)
 }
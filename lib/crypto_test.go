@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	data, key := []byte("compressembed"), []byte("secret")
+	enc, err := Encrypt(data, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(enc, data) {
+		t.Fatal("ciphertext contains the plaintext")
+	}
+	again, err := Encrypt(data, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(enc, again) {
+		t.Fatal("nonce reused between encryptions")
+	}
+	dec, err := Decrypt(enc, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, data) {
+		t.Fatalf("got %q, want %q", dec, data)
+	}
+
+	if _, err := Decrypt(enc, []byte("wrong")); err == nil {
+		t.Fatal("decrypted with the wrong key")
+	}
+	enc[len(enc)-1] ^= 1
+	if _, err := Decrypt(enc, key); err == nil {
+		t.Fatal("decrypted tampered ciphertext")
+	}
+	if _, err := Decrypt(enc[:4], key); err != errCiphertext {
+		t.Fatalf("got %v, want %v", err, errCiphertext)
+	}
+}
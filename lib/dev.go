@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const DefaultDevEnv = "COMPRESSEMBED_ROOT"
+
+func buildConstraint(tags, tag string) string {
+	switch {
+	case tags == "":
+		return tag
+	case strings.Contains(tags, "||"):
+		return "(" + tags + ") && " + tag
+	}
+	return tags + " && " + tag
+}
+
+func (cfg Config) devPath(input string) string {
+	abs, err := filepath.Abs(input)
+	if err != nil {
+		return filepath.ToSlash(input)
+	}
+	dir, err := filepath.Abs(filepath.Dir(cfg.Src))
+	if err != nil {
+		return filepath.ToSlash(input)
+	}
+	root, ok := moduleRoot(dir)
+	if !ok {
+		root = dir
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return filepath.ToSlash(input)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func devImports(resources []resource) []string {
+	seen := map[string]bool{"fmt": true, "os": true, "path/filepath": true, "runtime": true}
+	for _, r := range resources {
+		if r.Open != "" {
+			seen["io"] = true
+		}
+		if r.Gzip != "" {
+			seen["bytes"], seen["compress/gzip"] = true, true
+		}
+		if r.Archive {
+			seen["github.com/lecuong04/compressembed/lib/archive"] = true
+		}
+	}
+	list := make([]string, 0, len(seen))
+	for imp := range seen {
+		list = append(list, imp)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,16 @@
+package lib
+
+import "testing"
+
+func TestBuildConstraint(t *testing.T) {
+	for _, tt := range []struct{ tags, want string }{
+		{"", "dev"},
+		{"linux", "linux && dev"},
+		{"linux && !arm", "linux && !arm && dev"},
+		{"linux || darwin", "(linux || darwin) && dev"},
+	} {
+		if got := buildConstraint(tt.tags, "dev"); got != tt.want {
+			t.Errorf("buildConstraint(%q) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	DefaultDictSize = 32 << 10
+	dictDmer        = 8
+	dictSegment     = 128
+	dictStride      = dictSegment / 4
+)
+
+type dictCandidate struct {
+	sample int
+	start  int
+	end    int
+	score  int
+}
+
+type dictHeap []dictCandidate
+
+func (h dictHeap) Len() int            { return len(h) }
+func (h dictHeap) Less(i, j int) bool  { return h[i].score > h[j].score }
+func (h dictHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *dictHeap) Push(x interface{}) { *h = append(*h, x.(dictCandidate)) }
+func (h *dictHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func (cfg Config) dictionary() ([]byte, error) {
+	if cfg.Dict == "" {
+		return []byte(cfg.Key), nil
+	}
+	return os.ReadFile(cfg.Dict)
+}
+
+func ReadSamples(paths []string) ([][]byte, error) {
+	var samples [][]byte
+	for _, p := range paths {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || !d.Type().IsRegular() {
+					return err
+				}
+				data, err := os.ReadFile(p)
+				if err == nil && len(data) > 0 {
+					samples = append(samples, data)
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return samples, nil
+}
+
+func TrainDict(samples [][]byte, size int) []byte {
+	freq := map[uint64]int{}
+	for _, s := range samples {
+		seen := map[uint64]bool{}
+		for i := 0; i+dictDmer <= len(s); i++ {
+			d := binary.LittleEndian.Uint64(s[i:])
+			if !seen[d] {
+				seen[d] = true
+				freq[d]++
+			}
+		}
+	}
+	min := 2
+	if len(samples) == 1 {
+		min = 1
+	}
+	score := func(c dictCandidate) int {
+		s := samples[c.sample]
+		seen := map[uint64]bool{}
+		total := 0
+		for i := c.start; i+dictDmer <= c.end; i++ {
+			d := binary.LittleEndian.Uint64(s[i:])
+			if f := freq[d]; f >= min && !seen[d] {
+				seen[d] = true
+				total += f
+			}
+		}
+		return total
+	}
+
+	h := &dictHeap{}
+	for i, s := range samples {
+		for start := 0; start+dictDmer <= len(s); start += dictStride {
+			end := start + dictSegment
+			if end > len(s) {
+				end = len(s)
+			}
+			c := dictCandidate{sample: i, start: start, end: end}
+			if c.score = score(c); c.score > 0 {
+				*h = append(*h, c)
+			}
+		}
+	}
+	heap.Init(h)
+
+	var segments [][]byte
+	total := 0
+	for h.Len() > 0 && total < size {
+		c := heap.Pop(h).(dictCandidate)
+		if c.score = score(c); c.score == 0 {
+			continue
+		}
+		if h.Len() > 0 && c.score < (*h)[0].score {
+			heap.Push(h, c)
+			continue
+		}
+		s := samples[c.sample]
+		for i := c.start; i+dictDmer <= c.end; i++ {
+			delete(freq, binary.LittleEndian.Uint64(s[i:]))
+		}
+		segments = append(segments, s[c.start:c.end])
+		total += c.end - c.start
+	}
+
+	dict := make([]byte, 0, total)
+	for i := len(segments) - 1; i >= 0; i-- {
+		dict = append(dict, segments[i]...)
+	}
+	if len(dict) > size {
+		dict = dict[len(dict)-size:]
+	}
+	return dict
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func dictSamples() [][]byte {
+	var samples [][]byte
+	for i := 0; i < 40; i++ {
+		samples = append(samples, []byte(fmt.Sprintf(`{"id": %d, "name": "user%d", "email": "user%d@example.com", "active": %t, "roles": ["reader", "writer"], "created": "2024-01-%02dT10:00:00Z"}`,
+			i, i*7, i*7, i%3 == 0, i%28+1)))
+	}
+	return samples
+}
+
+func TestTrainDict(t *testing.T) {
+	samples := dictSamples()
+	for _, size := range []int{16, 256, DefaultDictSize} {
+		dict := TrainDict(samples, size)
+		if len(dict) == 0 || len(dict) > size {
+			t.Fatalf("size %d: got %d bytes", size, len(dict))
+		}
+		if !bytes.Equal(dict, TrainDict(samples, size)) {
+			t.Fatalf("size %d: training is not deterministic", size)
+		}
+	}
+	if dict := TrainDict(nil, DefaultDictSize); len(dict) != 0 {
+		t.Fatalf("no samples: got %d bytes", len(dict))
+	}
+}
+
+func TestTrainDictCompression(t *testing.T) {
+	samples := dictSamples()
+	dict := TrainDict(samples[:30], 1<<10)
+	for _, name := range []string{"zlib", "zstd"} {
+		c, _ := GetCodec(name)
+		plain, trained := 0, 0
+		for _, s := range samples[30:] {
+			enc, err := c.Compress(s, nil, c.DefaultLevel())
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain += len(enc)
+			if enc, err = c.Compress(s, dict, c.DefaultLevel()); err != nil {
+				t.Fatal(err)
+			}
+			trained += len(enc)
+		}
+		if trained >= plain {
+			t.Fatalf("%s: %d bytes with dictionary, %d without", name, trained, plain)
+		}
+	}
+}
+
+func TestReadSamples(t *testing.T) {
+	dir := t.TempDir()
+	for i, s := range dictSamples()[:3] {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.json", i)), s, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty.json"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	samples, err := ReadSamples([]string{filepath.Join(dir, "*.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(samples))
+	}
+}
@@ -0,0 +1,200 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"go/build/constraint"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lecuong04/compressembed/lib/archive"
+)
+
+const (
+	StreamAlongside = "alongside"
+	StreamOnly      = "only"
+)
+
+type Embed struct {
+	Input  string
+	Var    string
+	Output string
+	Tags   string
+}
+
+type embedGroup struct {
+	Tags   string
+	Embeds []Embed
+	Inputs [][]byte
+}
+
+type resource struct {
+	Embed
+	Name    string
+	Load    string
+	Open    string
+	Gzip    string
+	Must    string
+	Eager   bool
+	Data    string
+	Type    string
+	Digest  string
+	Content string
+	Solid   string
+	Archive bool
+	Offset  int
+	End     int
+	Size    int
+	Entries []archive.Entry
+	DevPath string
+}
+
+type dataFile struct {
+	Var    string
+	Output string
+	data   []byte
+}
+
+func (f *dataFile) Literal() string {
+	const chunk = 32
+	var b strings.Builder
+	b.WriteString("strings.Join([]string{")
+	for data := f.data; len(data) > 0; {
+		n := chunk
+		if n > len(data) {
+			n = len(data)
+		}
+		b.WriteString("\n\t\"")
+		for _, c := range data[:n] {
+			switch {
+			case c == '"' || c == '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case c >= ' ' && c <= '~':
+				b.WriteByte(c)
+			default:
+				fmt.Fprintf(&b, "\\x%02x", c)
+			}
+		}
+		b.WriteString("\",")
+		data = data[n:]
+	}
+	b.WriteString("\n}, \"\")")
+	return b.String()
+}
+
+func ParseEmbed(s string) (Embed, error) {
+	var e Embed
+	for _, field := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || v == "" {
+			return e, fmt.Errorf("malformed embed field %q", field)
+		}
+		switch k {
+		case "in":
+			e.Input = v
+		case "var":
+			e.Var = v
+		case "out":
+			e.Output = v
+		case "tags":
+			if _, err := constraint.Parse("//go:build " + v); err != nil {
+				return e, fmt.Errorf("malformed build constraint %q: %w", v, err)
+			}
+			e.Tags = v
+		default:
+			return e, fmt.Errorf("unknown embed field %q", k)
+		}
+	}
+	if e.Input == "" || e.Var == "" {
+		return e, fmt.Errorf("embed %q requires in= and var=", s)
+	}
+	return e, nil
+}
+
+func ReadManifest(path string) ([]Embed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var embeds []Embed
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, err := ParseEmbed(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		embeds = append(embeds, e)
+	}
+	return embeds, sc.Err()
+}
+
+func (cfg Config) embeds() []Embed {
+	var embeds []Embed
+	if cfg.Input != "" || len(cfg.Embeds) == 0 {
+		embeds = append(embeds, Embed{Input: cfg.Input, Var: cfg.Var})
+	}
+	embeds = append(embeds, cfg.Embeds...)
+	for i, e := range embeds {
+		switch {
+		case e.Output != "":
+		case cfg.Split:
+			embeds[i].Output = taggedName(e.Var+".dat", e.Tags)
+		default:
+			embeds[i].Output = taggedName(cfg.Output, e.Tags)
+		}
+	}
+	return embeds
+}
+
+func groupEmbeds(embeds []Embed, inputs [][]byte) []embedGroup {
+	groups := []embedGroup{{}}
+	index := map[string]int{"": 0}
+	for i, e := range embeds {
+		g, ok := index[e.Tags]
+		if !ok {
+			g = len(groups)
+			index[e.Tags] = g
+			groups = append(groups, embedGroup{Tags: e.Tags})
+		}
+		groups[g].Embeds = append(groups[g].Embeds, e)
+		if inputs != nil {
+			groups[g].Inputs = append(groups[g].Inputs, inputs[i])
+		}
+	}
+	if len(groups[0].Embeds) == 0 {
+		groups = groups[1:]
+	}
+	return groups
+}
+
+func (cfg Config) group(i int, g embedGroup) Config {
+	if g.Tags != "" {
+		cfg.Func += strconv.Itoa(i)
+		cfg.Src = taggedName(cfg.Src, g.Tags)
+		cfg.Output = taggedName(cfg.Output, g.Tags)
+	}
+	return cfg
+}
+
+var tagWords = strings.NewReplacer("!", " not ", "&&", " and ", "||", " or ")
+
+func taggedName(name, tags string) string {
+	if tags == "" {
+		return name
+	}
+	words := strings.FieldsFunc(tagWords.Replace(tags), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + strings.Join(words, "-") + ext
+}
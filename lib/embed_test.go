@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEmbed(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Embed
+		err  string
+	}{
+		{in: "in=a.txt,var=A", want: Embed{Input: "a.txt", Var: "A"}},
+		{in: " in=web , var=W , out=web.dat , tags=linux && !arm ", want: Embed{Input: "web", Var: "W", Output: "web.dat", Tags: "linux && !arm"}},
+		{in: "in=a.txt,var", err: "malformed embed field"},
+		{in: "in=a.txt,var=", err: "malformed embed field"},
+		{in: "in=a.txt,var=A,mode=x", err: "unknown embed field"},
+		{in: "in=a.txt", err: "requires in= and var="},
+		{in: "var=A", err: "requires in= and var="},
+		{in: "in=a.txt,var=A,tags=linux &&", err: "malformed build constraint"},
+	} {
+		got, err := ParseEmbed(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got error %v, want %q", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "embeds.txt")
+	manifest := "# resources\n\nin=a.txt,var=A\n  in=b.txt,var=B,tags=linux\n"
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	embeds, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Embed{{Input: "a.txt", Var: "A"}, {Input: "b.txt", Var: "B", Tags: "linux"}}
+	if len(embeds) != len(want) {
+		t.Fatalf("got %+v, want %+v", embeds, want)
+	}
+	for i := range want {
+		if embeds[i] != want[i] {
+			t.Fatalf("got %+v, want %+v", embeds, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(manifest+"in=c.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadManifest(path); err == nil || !strings.Contains(err.Error(), path+":5:") {
+		t.Fatalf("got %v, want an error on line 5", err)
+	}
+	if _, err := ReadManifest(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Fatal("read a missing manifest")
+	}
+}
+
+func TestTaggedName(t *testing.T) {
+	for _, tt := range []struct{ name, tags, want string }{
+		{"resource.dat", "", "resource.dat"},
+		{"resource.dat", "linux", "resource.linux.dat"},
+		{"compressed.go", "!windows", "compressed.not-windows.go"},
+		{"compressed.go", "linux && (amd64 || arm64)", "compressed.linux-and-amd64-or-arm64.go"},
+		{"dir/data", "go1.21", "dir/data.go1-21"},
+	} {
+		if got := taggedName(tt.name, tt.tags); got != tt.want {
+			t.Errorf("taggedName(%q, %q) = %q, want %q", tt.name, tt.tags, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var goDirective = regexp.MustCompile(`^go\s+(\d+)\.(\d+)`)
+
+func parseGoVersion(s string) (major, minor int, ok bool) {
+	m := goDirective.FindStringSubmatch("go " + s)
+	if m == nil {
+		return 0, 0, false
+	}
+	fmt.Sscan(m[1], &major)
+	fmt.Sscan(m[2], &minor)
+	return major, minor, true
+}
+
+func moduleRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func moduleGoVersion(dir string) string {
+	root, ok := moduleRoot(dir)
+	if !ok {
+		return ""
+	}
+	f, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if m := goDirective.FindStringSubmatch(sc.Text()); m != nil {
+			return m[1] + "." + m[2]
+		}
+	}
+	return ""
+}
+
+func (cfg Config) targetGoVersion() string {
+	if cfg.GoVersion != "" {
+		return cfg.GoVersion
+	}
+	dir, err := filepath.Abs(filepath.Dir(cfg.Src))
+	if err != nil {
+		return ""
+	}
+	return moduleGoVersion(dir)
+}
+
+func (cfg Config) goAtLeast(major, minor int) bool {
+	ma, mi, ok := parseGoVersion(cfg.targetGoVersion())
+	return ok && (ma > major || ma == major && mi >= minor)
+}
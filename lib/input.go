@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/lecuong04/compressembed/lib/archive"
+)
+
+const DefaultBlockSize = 256 << 10
+
+func (cfg Config) blockSize() int {
+	if !cfg.Solid {
+		return 0
+	}
+	if cfg.BlockSize <= 0 {
+		return DefaultBlockSize
+	}
+	return cfg.BlockSize
+}
+
+func (cfg Config) reportArchive(w io.Writer, e Embed, codec Codec, dict []byte, size int) {
+	mode, other := "per-file", "solid"
+	if cfg.Solid {
+		mode, other = other, mode
+	}
+	alt := cfg
+	alt.Solid = !cfg.Solid
+	data, err := archive.Pack(e.Input, NewArchiveWriter(codec, dict, cfg.Level, alt.blockSize()))
+	if err != nil {
+		return
+	}
+	diff := size - len(data)
+	fmt.Fprintf(w, "Archive %s: %s %d bytes, %s %d bytes (%+d bytes, %+.1f%%)\n",
+		e.Var, mode, size, other, len(data), diff, 100*float64(diff)/float64(len(data)))
+}
+
+// ContentDigest hashes the paths, file types, executable bits and file contents
+// of archive entries. Mod times and other permission bits are left out as they
+// do not survive a fresh checkout.
+func ContentDigest(entries []archive.Entry) string {
+	h := sha256.New()
+	for _, e := range entries {
+		fmt.Fprintf(h, "%q %o ", e.Path, uint32(e.Mode&(fs.ModeType|0o111)))
+		if !e.Mode.IsDir() {
+			h.Write(e.Sum[:])
+		}
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (cfg Config) solidMode() string {
+	if size := cfg.blockSize(); size > 0 {
+		return fmt.Sprintf("solid %d", size)
+	}
+	return "per-file"
+}
+
+func IsArchiveInput(in string) bool {
+	if strings.ContainsAny(in, "*?[") {
+		return true
+	}
+	info, err := os.Stat(in)
+	return err == nil && info.IsDir()
+}
+
+func ReadInput(in string) ([]byte, error) {
+	if !IsArchiveInput(in) {
+		return os.ReadFile(in)
+	}
+	return archive.Pack(in, &archive.Writer{})
+}
+
+func NewArchiveReader(r io.ReaderAt, size int64, key []byte) (*archive.Reader, error) {
+	ar, err := archive.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	ar.Decompress = func(codec string, data []byte) ([]byte, error) {
+		return Decompress(codec, data, key)
+	}
+	return ar, nil
+}
+
+func NewArchiveWriter(codec Codec, dict []byte, level, blockSize int) *archive.Writer {
+	return &archive.Writer{
+		Codec: codec.Name(),
+		Compress: func(data []byte) ([]byte, error) {
+			return codec.Compress(data, dict, level)
+		},
+		BlockSize: blockSize,
+	}
+}
@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	KeyLiteral = "literal"
+	KeyEnv     = "env"
+	KeyFile    = "file"
+	KeyFunc    = "func"
+
+	DefaultSetKey = "SetKeyFunc"
+)
+
+func ParseKeySource(s string) (kind, arg string, err error) {
+	kind, arg, _ = strings.Cut(s, ":")
+	switch kind {
+	case "", KeyLiteral:
+		return KeyLiteral, "", nil
+	case KeyFunc:
+		return kind, "", nil
+	case KeyEnv, KeyFile:
+		if arg == "" {
+			return "", "", fmt.Errorf("key source %q requires a name", kind)
+		}
+		return kind, arg, nil
+	}
+	return "", "", fmt.Errorf("unknown key source %q", s)
+}
+
+func LoadKey(kind, arg string) (string, bool) {
+	switch kind {
+	case KeyEnv:
+		return os.LookupEnv(arg)
+	case KeyFile:
+		key, err := os.ReadFile(arg)
+		if err != nil {
+			return "", false
+		}
+		return string(bytes.TrimSpace(key)), true
+	}
+	return "", false
+}
+
+func accessorName(prefix, name string) string {
+	if name == "" || unicode.IsUpper(rune(name[0])) {
+		return prefix + name
+	}
+	return strings.ToLower(prefix[:1]) + prefix[1:] + strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseKeySource(t *testing.T) {
+	for _, tt := range []struct {
+		in, kind, arg string
+		ok            bool
+	}{
+		{"", KeyLiteral, "", true},
+		{"literal", KeyLiteral, "", true},
+		{"func", KeyFunc, "", true},
+		{"env:APP_KEY", KeyEnv, "APP_KEY", true},
+		{"file:/etc/app.key", KeyFile, "/etc/app.key", true},
+		{"file:C:\\app.key", KeyFile, "C:\\app.key", true},
+		{"env", "", "", false},
+		{"file:", "", "", false},
+		{"vault:app", "", "", false},
+	} {
+		kind, arg, err := ParseKeySource(tt.in)
+		if (err == nil) != tt.ok || kind != tt.kind || arg != tt.arg {
+			t.Errorf("%q: got %q, %q, %v", tt.in, kind, arg, err)
+		}
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	t.Setenv("COMPRESSEMBED_TEST_KEY", "env secret")
+	path := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(path, []byte("  file secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		kind, arg, key string
+		ok             bool
+	}{
+		{KeyEnv, "COMPRESSEMBED_TEST_KEY", "env secret", true},
+		{KeyEnv, "COMPRESSEMBED_TEST_UNSET", "", false},
+		{KeyFile, path, "file secret", true},
+		{KeyFile, path + ".missing", "", false},
+		{KeyLiteral, "", "", false},
+		{KeyFunc, "", "", false},
+	} {
+		if key, ok := LoadKey(tt.kind, tt.arg); key != tt.key || ok != tt.ok {
+			t.Errorf("%s:%s: got %q, %t", tt.kind, tt.arg, key, ok)
+		}
+	}
+}
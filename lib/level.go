@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	HuffmanOnly   = -2
+	DefaultLevel  = -1
+	NoCompression = 0
+)
+
+func levelRange(min, max int) []int {
+	levels := make([]int, 0, max-min+1)
+	for l := min; l <= max; l++ {
+		levels = append(levels, l)
+	}
+	return levels
+}
+
+func ParseLevel(s string) (int, error) {
+	switch strings.ToLower(s) {
+	case "default", "":
+		return DefaultLevel, nil
+	case "none":
+		return NoCompression, nil
+	case "huffman":
+		return HuffmanOnly, nil
+	}
+	l, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid level %q", s)
+	}
+	return l, nil
+}
+
+func LevelName(level int) string {
+	switch level {
+	case DefaultLevel:
+		return "default"
+	case NoCompression:
+		return "none"
+	case HuffmanOnly:
+		return "huffman"
+	}
+	return strconv.Itoa(level)
+}
+
+func ResolveLevel(c Codec, level int) (int, error) {
+	if level == DefaultLevel {
+		return c.DefaultLevel(), nil
+	}
+	levels := c.Levels()
+	for _, l := range levels {
+		if l == level {
+			return level, nil
+		}
+	}
+	names := make([]string, len(levels))
+	for i, l := range levels {
+		names[i] = LevelName(l)
+	}
+	return 0, fmt.Errorf("level %s is not supported by %s (%s)", LevelName(level), c.Name(), strings.Join(names, ", "))
+}
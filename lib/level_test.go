@@ -0,0 +1,47 @@
+package lib
+
+import "testing"
+
+func TestParseLevel(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"", DefaultLevel, true},
+		{"default", DefaultLevel, true},
+		{"None", NoCompression, true},
+		{"huffman", HuffmanOnly, true},
+		{"9", 9, true},
+		{"-1", DefaultLevel, true},
+		{"best", 0, false},
+	} {
+		got, err := ParseLevel(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseLevel(%q) = %d, %v", tt.in, got, err)
+		}
+		if tt.ok {
+			if back, err := ParseLevel(LevelName(got)); err != nil || back != got {
+				t.Errorf("LevelName(%d) = %q does not parse back", got, LevelName(got))
+			}
+		}
+	}
+}
+
+func TestResolveLevel(t *testing.T) {
+	for _, name := range CodecNames() {
+		c := codecs[name]
+		got, err := ResolveLevel(c, DefaultLevel)
+		if err != nil || got != c.DefaultLevel() {
+			t.Errorf("%s: default resolved to %d, %v", name, got, err)
+		}
+		for _, l := range c.Levels() {
+			if got, err := ResolveLevel(c, l); err != nil || got != l {
+				t.Errorf("%s: level %d resolved to %d, %v", name, l, got, err)
+			}
+		}
+		if _, err := ResolveLevel(c, 100); err == nil {
+			t.Errorf("%s: accepted level 100", name)
+		}
+	}
+}
@@ -1,32 +1,125 @@
 package lib
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	crand "crypto/rand"
+	"crypto/sha256"
 	_ "embed"
 	"encoding/hex"
-	"html/template"
+	"fmt"
+	"go/format"
 	"log"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+
+	"github.com/lecuong04/compressembed/lib/archive"
 )
 
 type Config struct {
-	Pkg    string
-	Func   string
-	Key    string
-	Input  string
-	Output string
-	Var    string
-	Src    string
+	Pkg       string
+	Func      string
+	Key       string
+	Input     string
+	Output    string
+	Var       string
+	Src       string
+	Codec     string
+	Level     int
+	Policy    string
+	Encrypt   bool
+	KeySource string
+	Obfuscate bool
+	SignKey   string
+	Embeds    []Embed
+	Split     bool
+	Solid     bool
+	BlockSize int
+	Dict      string
+	Lazy      bool
+	GoVersion string
+	Stream    string
+	Errors    bool
+	Template  string
+	Inline    bool
+	Dev       bool
+	DevEnv    string
+	SetKey    string
+}
+
+// source is the data passed to the built-in templates and to -template files.
+// The fields and helper funcs are described at the top of template.tmpl.
+type source struct {
+	Config
+	LevelName   string
+	OnceValue   bool
+	Archive     bool
+	PublicKey   string
+	Signer      string
+	KeyKind     string
+	KeyArg      string
+	KeyName     string
+	UnmaskName  string
+	KeyFuncName string
+	SetKeyFunc  bool
+	Shards      []shard
+	Decls       []shard
+	Imports     []string
+	Resources   []resource
+	Files       []*dataFile
+	Dictionary  *resource
+	Decoder     string
+	Tags        string
+	DevFile     bool
 }
 
 //go:embed template.tmpl
 var tmpl string
 
+//go:embed dev.tmpl
+var devTmpl string
+
+//go:embed setter.tmpl
+var setterTmpl string
+
+var funcs = template.FuncMap{
+	"quote": strconv.Quote,
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"export": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+	"replace": strings.ReplaceAll,
+	"base":    filepath.Base,
+	"comment": func(s string) string {
+		lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("// "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	},
+	"readFile": func(path string) (string, error) {
+		b, err := os.ReadFile(path)
+		return string(b), err
+	},
+	"embedPattern": func(s string) string {
+		if strings.ContainsAny(s, " \t\"`\\") {
+			return strconv.Quote(s)
+		}
+		return s
+	},
+}
+
 func KeyGen() string {
 	key := make([]byte, 32)
 	_, _ = crand.Read(key)
@@ -52,39 +145,338 @@ func IsValidVariableName(s string) bool {
 	return regex.MatchString(s)
 }
 
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func FileNameWithoutExtension(fileName string) string {
 	return filepath.Base(strings.TrimSuffix(fileName, filepath.Ext(fileName)))
 }
 
+func imports(cfg Config, c Codec, resources []resource) []string {
+	seen := map[string]bool{"bytes": true, "crypto/sha256": true, "encoding/hex": true, "errors": true, "fmt": true, "io": true}
+	for _, imp := range c.Imports() {
+		seen[imp] = true
+	}
+	switch kind, _, _ := ParseKeySource(cfg.KeySource); kind {
+	case KeyEnv, KeyFile:
+		seen["os"] = true
+	}
+	if cfg.Encrypt {
+		seen["crypto/aes"] = true
+		seen["crypto/cipher"] = true
+	}
+	if cfg.SignKey != "" {
+		seen["crypto/ed25519"] = true
+	}
+	if cfg.Inline {
+		seen["strings"] = true
+	}
+	if cfg.Stream != "" {
+		seen["hash"] = true
+	}
+	for _, r := range resources {
+		if r.Eager && (cfg.Lazy || cfg.Errors) {
+			seen["sync"] = true
+		}
+		if r.Archive {
+			seen["github.com/lecuong04/compressembed/lib/archive"] = true
+		}
+	}
+	list := make([]string, 0, len(seen))
+	for imp := range seen {
+		list = append(list, imp)
+	}
+	sort.Strings(list)
+	return list
+}
+
 func Run(cfg Config) {
-	data, err := os.ReadFile(cfg.Input)
+	embeds := cfg.embeds()
+	inputs := make([][]byte, len(embeds))
+	vars := map[string][]string{}
+	for i, e := range embeds {
+		data, err := ReadInput(e.Input)
+		if err != nil {
+			log.Fatal("Missing input file: ", err)
+		}
+		if !IsValidVariableName(e.Var) {
+			log.Fatal("Invalid variable name")
+		}
+		for _, tags := range vars[e.Var] {
+			if tags == "" || e.Tags == "" || tags == e.Tags {
+				log.Fatalf("Duplicate variable name %s", e.Var)
+			}
+		}
+		vars[e.Var] = append(vars[e.Var], e.Tags)
+		inputs[i] = data
+	}
+
+	keyKind, keyArg, err := ParseKeySource(cfg.KeySource)
 	if err != nil {
-		log.Fatal("Missing input file")
+		log.Fatal(err)
+	}
+	if key, ok := LoadKey(keyKind, keyArg); ok {
+		cfg.Key = key
+	} else if cfg.Key == "" {
+		if keyKind != KeyLiteral {
+			log.Fatalf("Key source %s cannot be resolved at generation time, pass -key", cfg.KeySource)
+		}
+		cfg.Key = KeyGen()
 	}
 
-	if !IsValidVariableName(cfg.Var) {
-		log.Fatal("Invalid variable name")
+	if cfg.Stream != "" && cfg.Stream != StreamAlongside && cfg.Stream != StreamOnly {
+		log.Fatalf("Unknown stream mode %q", cfg.Stream)
 	}
 
-	out, err := os.Create(cfg.Output)
+	if keyKind == KeyFunc && !IsValidVariableName(cfg.SetKey) {
+		log.Fatal("Invalid setter name")
+	}
+
+	if cfg.Obfuscate && keyKind != KeyLiteral {
+		log.Fatal("Key obfuscation requires the literal key source")
+	}
+
+	dict, err := cfg.dictionary()
 	if err != nil {
-		log.Fatal("Cannot create file")
+		log.Fatal("Missing dictionary file")
+	}
+
+	var priv ed25519.PrivateKey
+	if cfg.SignKey != "" {
+		priv, err = LoadSigningKey(cfg.SignKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	groups := groupEmbeds(embeds, inputs)
+	if keyKind == KeyFunc && groups[0].Tags != "" {
+		groups = append([]embedGroup{{}}, groups...)
+	}
+	seen := map[string]bool{cfg.Func: true, cfg.SetKey: true}
+	for _, e := range embeds {
+		seen[e.Var] = true
+	}
+	if cfg.Func == "" {
+		cfg.Func = privateName(seen)
+	}
+	keyFunc := privateName(seen)
+	for i, g := range groups {
+		cfg.group(i, g).generate(g, dict, priv, keyKind, keyArg, keyFunc)
+	}
+}
+
+func (cfg Config) generate(g embedGroup, dict []byte, priv ed25519.PrivateKey, keyKind, keyArg, keyFunc string) {
+	embeds, inputs := g.Embeds, g.Inputs
+	if len(embeds) == 0 {
+		writeSource(cfg.parseTemplate(setterTmpl), source{Config: cfg, KeyFuncName: keyFunc}, cfg.Src)
+		return
+	}
+	if cfg.Codec == AutoCodec {
+		best, err := SelectCodec(bytes.Join(inputs, nil), dict, cfg.Dict != "", cfg.Level, cfg.Policy, os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Codec, cfg.Level = best.Codec, best.Level
 	}
-	_, _ = out.Write(Compress(data, []byte(cfg.Key)))
-	out.Close()
 
-	srcf, err := os.Create(cfg.Src)
+	codec, err := GetCodec(cfg.Codec)
 	if err != nil {
-		log.Fatal("Cannot create file")
+		log.Fatal(err)
 	}
-	defer srcf.Close()
 
-	src, err := template.New(cfg.Src).Parse(tmpl)
+	cfg.Level, err = ResolveLevel(codec, cfg.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Dict != "" && !codec.Dictionary() {
+		log.Fatalf("Codec %s does not support -dict", cfg.Codec)
+	}
+	if cfg.Stream != "" && codec.Name() == "lz4" {
+		log.Print("Codec lz4 does not stream, Open accessors decode the whole resource into memory")
+	}
+
+	var pub []byte
+	if priv != nil {
+		pub = priv.Public().(ed25519.PublicKey)
+	}
+
+	var files []*dataFile
+	outputs := map[string]*dataFile{}
+	store := func(output string, payload []byte) resource {
+		if cfg.Encrypt {
+			payload, err = Encrypt(payload, []byte(cfg.Key))
+			if err != nil {
+				log.Fatal("Cannot encrypt output")
+			}
+		}
+
+		if priv != nil {
+			payload = Sign(payload, priv)
+		}
+
+		f := outputs[output]
+		if f == nil {
+			f = &dataFile{Var: cfg.Func + "Data", Output: output}
+			if len(files) > 0 {
+				f.Var += strconv.Itoa(len(files))
+			}
+			if cfg.Inline {
+				f.Output = f.Var
+			}
+			outputs[output] = f
+			files = append(files, f)
+		}
+		r := resource{
+			Embed:  Embed{Output: f.Output},
+			Data:   f.Var,
+			Offset: len(f.data),
+			End:    len(f.data) + len(payload),
+		}
+		f.data = append(f.data, payload...)
+		return r
+	}
+
+	resources := make([]resource, len(embeds))
+	archived := false
+	for i, e := range embeds {
+		var compressed []byte
+		var entries []archive.Entry
+		digest, isArchive := Digest(inputs[i]), IsArchiveInput(e.Input)
+		if isArchive {
+			w := NewArchiveWriter(codec, dict, cfg.Level, cfg.blockSize())
+			compressed, err = archive.Pack(e.Input, w)
+			if err == nil {
+				cfg.reportArchive(os.Stdout, e, codec, dict, len(compressed))
+				if files, saved := w.Deduplicated(); files > 0 {
+					fmt.Printf("Archive %s: %d duplicate files stored once, %d bytes saved\n", e.Var, files, saved)
+				}
+			}
+			digest = Digest(compressed)
+			entries = w.Entries()
+		} else {
+			compressed, err = codec.Compress(inputs[i], dict, cfg.Level)
+		}
+		if err != nil {
+			log.Fatal("Cannot compress input")
+		}
+
+		r := store(e.Output, compressed)
+		r.Input, r.Var, r.Tags = e.Input, e.Var, e.Tags
+		r.Name = cfg.Func + "_" + e.Var
+		r.Load = accessorName("Load", e.Var)
+		r.Must = accessorName("Must", e.Var)
+		r.Eager = isArchive || cfg.Stream != StreamOnly
+		if !isArchive && cfg.Stream != "" {
+			r.Open = accessorName("Open", e.Var)
+		}
+		if !isArchive && codec.Name() == "gzip" && !cfg.Encrypt && priv == nil {
+			r.Gzip = e.Var + "Gzip"
+		}
+		r.Type = "[]byte"
+		r.Digest = digest
+		r.Archive = isArchive
+		r.Size = len(inputs[i])
+		r.Entries = entries
+		if cfg.Dev {
+			r.DevPath = cfg.devPath(e.Input)
+		}
+		if r.Archive {
+			r.Type = "*archive.FS"
+			r.Content, r.Solid = ContentDigest(entries), cfg.solidMode()
+			archived = true
+		}
+		resources[i] = r
+	}
+
+	var dictionary *resource
+	if cfg.Dict != "" {
+		r := store(cfg.Output, dict)
+		r.Digest = Digest(dict)
+		dictionary = &r
+	}
+
+	if !cfg.Inline {
+		for _, f := range files {
+			if err := os.WriteFile(f.Output, f.data, 0o644); err != nil {
+				log.Fatal("Cannot create file")
+			}
+		}
+	}
+
+	seen := map[string]bool{cfg.Func: true, keyFunc: true}
+	for _, e := range embeds {
+		seen[e.Var] = true
+	}
+	keyName, unmaskName := privateName(seen), privateName(seen)
+	var shards, decls []shard
+	if cfg.Obfuscate {
+		shards, decls = obfuscateKey([]byte(cfg.Key), seen)
+	}
+	data := source{
+		Config:      cfg,
+		KeyName:     keyName,
+		UnmaskName:  unmaskName,
+		KeyFuncName: keyFunc,
+		SetKeyFunc:  g.Tags == "",
+		LevelName:   LevelName(cfg.Level),
+		OnceValue:   cfg.Lazy && cfg.goAtLeast(1, 21),
+		Archive:     archived,
+		PublicKey:   byteList(pub),
+		Signer:      hex.EncodeToString(pub),
+		KeyKind:     keyKind,
+		KeyArg:      keyArg,
+		Shards:      shards,
+		Decls:       decls,
+		Imports:     imports(cfg, codec, resources),
+		Resources:   resources,
+		Files:       files,
+		Dictionary:  dictionary,
+		Decoder:     codec.Decoder(),
+		Tags:        g.Tags,
+	}
+	if !cfg.Dev {
+		writeSource(cfg.parseTemplate(tmpl), data, cfg.Src)
+		return
+	}
+	data.Tags = buildConstraint(g.Tags, "!dev")
+	writeSource(cfg.parseTemplate(tmpl), data, cfg.Src)
+
+	data.DevFile = true
+	data.Tags = buildConstraint(g.Tags, "dev")
+	data.Imports = devImports(resources)
+	writeSource(cfg.parseTemplate(devTmpl), data, taggedName(cfg.Src, "dev"))
+}
+
+func (cfg Config) parseTemplate(builtin string) *template.Template {
+	t, err := template.New("compressembed").Funcs(funcs).Parse(builtin)
 	if err != nil {
 		log.Fatal("Cannot parse text")
 	}
-	err = src.Execute(srcf, cfg)
+	if cfg.Template != "" {
+		text, err := os.ReadFile(cfg.Template)
+		if err != nil {
+			log.Fatal("Missing template file: ", err)
+		}
+		if t, err = t.New(filepath.Base(cfg.Template)).Parse(string(text)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return t
+}
+
+func writeSource(t *template.Template, data source, path string) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Fatal("Cannot write file: ", err)
+	}
+	out, err := format.Source(buf.Bytes())
 	if err != nil {
-		log.Fatal("Cannot write file")
+		log.Fatal("Generated source does not parse: ", err)
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		log.Fatal("Cannot create file")
 	}
 }
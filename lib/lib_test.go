@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const generateMain = `package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+)
+
+func must(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func readAll(r io.ReadCloser, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	defer r.Close()
+	return must(io.ReadAll(r))
+}
+
+func readFile(fsys fs.FS, name string) []byte {
+	return must(fs.ReadFile(fsys, name))
+}
+
+func gunzip(data []byte) []byte {
+	return readAll(gzip.NewReader(bytes.NewReader(data)))
+}
+
+func main() {
+	%s
+	if got, want := %s, must(os.ReadFile(%q)); !bytes.Equal(got, want) {
+		panic("decoded resource differs from its input")
+	}
+}
+`
+
+const generateTemplate = `{{template "compressembed" .}}
+
+func Generated() string {
+	return {{quote .Codec}}
+}
+`
+
+type generateCase struct {
+	name   string
+	config func(cfg *Config)
+	setup  string
+	use    string
+	want   string
+}
+
+func baseConfig() Config {
+	return Config{
+		Pkg:       "main",
+		Input:     "data.txt",
+		Output:    "resource.dat",
+		Var:       "X",
+		Src:       "compressed.go",
+		Codec:     "zlib",
+		Level:     DefaultLevel,
+		Policy:    PolicySmallest,
+		KeySource: KeyLiteral,
+		BlockSize: DefaultBlockSize,
+		DevEnv:    DefaultDevEnv,
+		SetKey:    DefaultSetKey,
+	}
+}
+
+func generateCases() []generateCase {
+	const (
+		load   = "if err := LoadX(); err != nil {\n\t\tpanic(err)\n\t}"
+		setter = "SetKeyFunc(func() ([]byte, error) { return []byte(\"func secret\"), nil })\n\t" + load
+	)
+	var cases []generateCase
+	for _, name := range CodecNames() {
+		name := name
+		cases = append(cases, generateCase{name: "codec-" + name, config: func(cfg *Config) { cfg.Codec = name }})
+	}
+	return append(cases, []generateCase{
+		{name: "auto", config: func(cfg *Config) { cfg.Codec, cfg.Policy = AutoCodec, PolicyWeighted }},
+		{name: "level", config: func(cfg *Config) { cfg.Codec, cfg.Level = "zstd", HuffmanOnly }},
+		{name: "gzip-raw", config: func(cfg *Config) { cfg.Codec = "gzip" }, use: "gunzip(XGzip())"},
+		{name: "encrypt", config: func(cfg *Config) { cfg.Encrypt = true }},
+		{name: "key-env", config: func(cfg *Config) { cfg.Encrypt, cfg.KeySource = true, "env:COMPRESSEMBED_TEST_KEY" }, setup: load},
+		{name: "key-file", config: func(cfg *Config) { cfg.Encrypt, cfg.KeySource = true, "file:key.txt" }, setup: load},
+		{name: "key-func", config: func(cfg *Config) { cfg.Encrypt, cfg.KeySource, cfg.Key = true, KeyFunc, "func secret" }, setup: setter},
+		{name: "obfuscate", config: func(cfg *Config) { cfg.Encrypt, cfg.Obfuscate = true, true }},
+		{name: "sign", config: func(cfg *Config) { cfg.Encrypt, cfg.SignKey = true, "sign.hex" }},
+		{name: "lazy", config: func(cfg *Config) { cfg.Lazy, cfg.GoVersion = true, "1.20" }, use: "X()"},
+		{name: "lazy-once-value", config: func(cfg *Config) { cfg.Lazy, cfg.GoVersion = true, "1.21" }, use: "X()"},
+		{name: "stream", config: func(cfg *Config) { cfg.Codec, cfg.Stream = "zstd", StreamAlongside }, use: "readAll(OpenX())"},
+		{name: "stream-only", config: func(cfg *Config) { cfg.Stream, cfg.Lazy = StreamOnly, true }, use: "readAll(OpenX())"},
+		{name: "errors", config: func(cfg *Config) { cfg.Errors, cfg.Stream = true, StreamAlongside }, use: "must(X())"},
+		{name: "inline", config: func(cfg *Config) { cfg.Inline, cfg.Encrypt = true, true }},
+		{name: "dict", config: func(cfg *Config) { cfg.Codec, cfg.Dict = "zstd", "dict.bin" }},
+		{name: "archive", config: func(cfg *Config) { cfg.Input, cfg.Var = "web", "W" }, use: `readFile(W, "css/site.css")`, want: "web/css/site.css"},
+		{name: "archive-solid", config: func(cfg *Config) { cfg.Input, cfg.Var, cfg.Solid, cfg.BlockSize = "web/*/*.css", "W", true, 64 }, use: `readFile(W, "css/site.css")`, want: "web/css/site.css"},
+		{
+			name: "split",
+			config: func(cfg *Config) {
+				cfg.Split, cfg.Embeds = true, []Embed{{Input: "web/index.html", Var: "Index"}}
+			},
+			use:  "append(X[:len(X):len(X)], Index...)",
+			want: "joined.txt",
+		},
+		{
+			name: "tags",
+			config: func(cfg *Config) {
+				cfg.Input, cfg.Embeds = "", []Embed{{Input: "data.txt", Var: "X", Tags: "linux"}, {Input: "data.txt", Var: "X", Tags: "!linux"}}
+			},
+		},
+		{
+			name: "tags-key-func",
+			config: func(cfg *Config) {
+				cfg.Input, cfg.Embeds = "", []Embed{{Input: "data.txt", Var: "X", Tags: "linux || darwin"}, {Input: "data.txt", Var: "X", Tags: "!linux && !darwin"}}
+				cfg.Codec, cfg.KeySource, cfg.Key, cfg.Dict = "zstd", KeyFunc, "func secret", "dict.bin"
+			},
+			setup: setter,
+		},
+		{name: "dev", config: func(cfg *Config) { cfg.Dev, cfg.Stream = true, StreamAlongside }},
+		{name: "dev-errors", config: func(cfg *Config) { cfg.Dev, cfg.Errors, cfg.Codec = true, true, "gzip" }, use: "must(X())"},
+		{name: "dev-archive", config: func(cfg *Config) { cfg.Dev, cfg.Lazy, cfg.Input, cfg.Var = true, true, "web", "W" }, use: `readFile(W(), "index.html")`, want: "web/index.html"},
+		{name: "template", config: func(cfg *Config) { cfg.Template, cfg.Dev = "custom.tmpl", true }, use: "append(X[:len(X):len(X)], Generated()...)", want: "template.txt"},
+	}...)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func goTool(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generated sources with the go tool")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not found")
+	}
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COMPRESSEMBED_TEST_KEY", "env secret")
+	t.Setenv(DefaultDevEnv, "")
+	os.Unsetenv(DefaultDevEnv)
+
+	app := t.TempDir()
+	writeFiles(t, app, map[string]string{
+		"go.mod": fmt.Sprintf("module app\n\ngo 1.21\n\nrequire github.com/lecuong04/compressembed v0.0.0\n\nreplace github.com/lecuong04/compressembed => %s\n", root),
+	})
+	data := strings.Repeat("compressembed packs resources into Go sources\n", 200)
+	index := strings.Repeat("<p>hello</p>\n", 50)
+	cases := generateCases()
+	chdir(t, app)
+	for _, c := range cases {
+		dir := filepath.Join(app, c.name)
+		writeFiles(t, dir, map[string]string{
+			"data.txt":         data,
+			"joined.txt":       data + index,
+			"template.txt":     data + "zlib",
+			"key.txt":          "file secret\n",
+			"sign.hex":         strings.Repeat("07", 32),
+			"dict.bin":         strings.Repeat("resources into Go sources\n", 20),
+			"custom.tmpl":      generateTemplate,
+			"web/index.html":   index,
+			"web/css/site.css": strings.Repeat("body { margin: 0 }\n", 40),
+		})
+		if err := os.Chdir(dir); err != nil {
+			t.Fatal(err)
+		}
+		cfg := baseConfig()
+		c.config(&cfg)
+		Run(cfg)
+
+		use, want := c.use, c.want
+		if use == "" {
+			use = "X"
+		}
+		if want == "" {
+			want = "data.txt"
+		}
+		writeFiles(t, dir, map[string]string{"main.go": fmt.Sprintf(generateMain, c.setup, use, want)})
+	}
+
+	bin := filepath.Join(app, "bin")
+	goTool(t, app, "vet", "./...")
+	goTool(t, app, "build", "-o", bin+string(filepath.Separator), "./...")
+	goTool(t, app, "vet", "-tags", "dev", "./...")
+	goTool(t, app, "build", "-tags", "dev", "-o", bin+"-dev"+string(filepath.Separator), "./...")
+	for _, c := range cases {
+		builds := []string{filepath.Join(bin, c.name)}
+		if strings.HasPrefix(c.name, "dev") || c.name == "template" {
+			builds = append(builds, filepath.Join(bin+"-dev", c.name))
+		}
+		for _, b := range builds {
+			cmd := exec.Command(b)
+			cmd.Dir = filepath.Join(app, c.name)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%s: %v\n%s", b, err, out)
+			}
+		}
+	}
+}
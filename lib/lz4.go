@@ -0,0 +1,259 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	lz4MinMatch  = 4
+	lz4MaxOffset = 65535
+	lz4HashLog   = 16
+	lz4LastLits  = 5
+	lz4MatchEnd  = 12
+	lz4MaxLevel  = 9
+
+	lz4MaxExpansion = 255
+)
+
+var errLZ4Corrupt = errors.New("lz4: corrupt input")
+
+type lz4Codec struct{}
+
+func (lz4Codec) Name() string { return "lz4" }
+
+func (lz4Codec) Levels() []int { return levelRange(NoCompression, lz4MaxLevel) }
+
+func (lz4Codec) DefaultLevel() int { return 1 }
+
+func (lz4Codec) Compress(data, dict []byte, level int) ([]byte, error) {
+	depth := 0
+	if level > NoCompression {
+		depth = 1 << (level - 1)
+	}
+	return lz4Encode(data, dict, depth), nil
+}
+
+func (lz4Codec) Decompress(data, dict []byte) ([]byte, error) {
+	return lz4Decode(data, dict)
+}
+
+func (lz4Codec) NewReader(r io.Reader, dict []byte) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	out, err := lz4Decode(data, dict)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(out)), nil
+}
+
+func (lz4Codec) Dictionary() bool { return true }
+
+func (lz4Codec) Imports() []string { return []string{"errors"} }
+
+func (lz4Codec) Decoder() string { return lz4Decoder }
+
+func lz4Hash(b []byte) uint32 {
+	return (binary.LittleEndian.Uint32(b) * 2654435761) >> (32 - lz4HashLog)
+}
+
+func lz4Length(out []byte, n int) []byte {
+	for n >= 255 {
+		out = append(out, 255)
+		n -= 255
+	}
+	return append(out, byte(n))
+}
+
+func lz4Encode(src, dict []byte, depth int) []byte {
+	buf := make([]byte, 0, len(dict)+len(src))
+	buf = append(append(buf, dict...), src...)
+	head := make([]int32, 1<<lz4HashLog)
+	chain := make([]int32, len(buf))
+	next := 0
+	insert := func(end int) {
+		for ; next < end; next++ {
+			if next+lz4MinMatch > len(buf) {
+				continue
+			}
+			h := lz4Hash(buf[next:])
+			chain[next] = head[h]
+			head[h] = int32(next + 1)
+		}
+	}
+	insert(len(dict))
+
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(src)))
+	anchor := len(dict)
+	limit := len(buf) - lz4MatchEnd
+	matchLimit := len(buf) - lz4LastLits
+	for pos := anchor; pos < limit; {
+		insert(pos)
+		bestOff, bestLen := 0, 0
+		cand := int(head[lz4Hash(buf[pos:])]) - 1
+		for i := 0; i < depth && cand >= 0 && pos-cand <= lz4MaxOffset; i++ {
+			n := 0
+			for pos+n < matchLimit && buf[cand+n] == buf[pos+n] {
+				n++
+			}
+			if n > bestLen {
+				bestOff, bestLen = pos-cand, n
+			}
+			cand = int(chain[cand]) - 1
+		}
+		if bestLen < lz4MinMatch {
+			pos++
+			continue
+		}
+		lits := pos - anchor
+		ml := bestLen - lz4MinMatch
+		token := byte(0)
+		if lits >= 15 {
+			token = 15 << 4
+		} else {
+			token = byte(lits) << 4
+		}
+		if ml >= 15 {
+			token |= 15
+		} else {
+			token |= byte(ml)
+		}
+		out = append(out, token)
+		if lits >= 15 {
+			out = lz4Length(out, lits-15)
+		}
+		out = append(out, buf[anchor:pos]...)
+		out = append(out, byte(bestOff), byte(bestOff>>8))
+		if ml >= 15 {
+			out = lz4Length(out, ml-15)
+		}
+		pos += bestLen
+		anchor = pos
+	}
+	lits := len(buf) - anchor
+	if lits >= 15 {
+		out = append(out, 15<<4)
+		out = lz4Length(out, lits-15)
+	} else {
+		out = append(out, byte(lits)<<4)
+	}
+	return append(out, buf[anchor:]...)
+}
+
+func lz4Decode(data, dict []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errLZ4Corrupt
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size > lz4MaxExpansion*len(data) {
+		return nil, errLZ4Corrupt
+	}
+	out := make([]byte, len(dict), len(dict)+size)
+	copy(out, dict)
+	for i := 4; i < len(data); {
+		token := data[i]
+		i++
+		n := int(token >> 4)
+		for b := byte(255); n >= 15 && b == 255 && i < len(data); i++ {
+			b = data[i]
+			n += int(b)
+		}
+		if n > len(data)-i || len(out)-len(dict)+n > size {
+			return nil, errLZ4Corrupt
+		}
+		out = append(out, data[i:i+n]...)
+		i += n
+		if i == len(data) {
+			break
+		}
+		if i+2 > len(data) {
+			return nil, errLZ4Corrupt
+		}
+		off := int(data[i]) | int(data[i+1])<<8
+		i += 2
+		m := int(token & 15)
+		for b := byte(255); m >= 15 && b == 255 && i < len(data); i++ {
+			b = data[i]
+			m += int(b)
+		}
+		m += lz4MinMatch
+		if off == 0 || off > len(out) || len(out)-len(dict)+m > size {
+			return nil, errLZ4Corrupt
+		}
+		for start := len(out) - off; m > 0; {
+			c := m
+			if c > len(out)-start {
+				c = len(out) - start
+			}
+			out = append(out, out[start:start+c]...)
+			m -= c
+		}
+	}
+	if len(out)-len(dict) != size {
+		return nil, errLZ4Corrupt
+	}
+	return out[len(dict):], nil
+}
+
+const lz4Decoder = `data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	corrupt := errors.New("lz4: corrupt input")
+	if len(data) < 4 {
+		return nil, corrupt
+	}
+	size := int(data[0]) | int(data[1])<<8 | int(data[2])<<16 | int(data[3])<<24
+	if size > 255*len(data) {
+		return nil, corrupt
+	}
+	out := make([]byte, len(dict), len(dict)+size)
+	copy(out, dict)
+	for i := 4; i < len(data); {
+		token := data[i]
+		i++
+		n := int(token >> 4)
+		for b := byte(255); n >= 15 && b == 255 && i < len(data); i++ {
+			b = data[i]
+			n += int(b)
+		}
+		if n > len(data)-i || len(out)-len(dict)+n > size {
+			return nil, corrupt
+		}
+		out = append(out, data[i:i+n]...)
+		i += n
+		if i == len(data) {
+			break
+		}
+		if i+2 > len(data) {
+			return nil, corrupt
+		}
+		off := int(data[i]) | int(data[i+1])<<8
+		i += 2
+		m := int(token & 15)
+		for b := byte(255); m >= 15 && b == 255 && i < len(data); i++ {
+			b = data[i]
+			m += int(b)
+		}
+		m += 4
+		if off == 0 || off > len(out) || len(out)-len(dict)+m > size {
+			return nil, corrupt
+		}
+		for start := len(out) - off; m > 0; {
+			c := m
+			if c > len(out)-start {
+				c = len(out) - start
+			}
+			out = append(out, out[start:start+c]...)
+			m -= c
+		}
+	}
+	if len(out)-len(dict) != size {
+		return nil, corrupt
+	}
+	return io.NopCloser(bytes.NewReader(out[len(dict):])), nil`
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLZ4Corrupt(t *testing.T) {
+	enc := lz4Encode(testData()["text"], nil, 4)
+	for _, data := range [][]byte{
+		nil,
+		{1, 0},
+		enc[:len(enc)-1],
+		append(append([]byte(nil), enc...), 0),
+	} {
+		if _, err := lz4Decode(data, nil); err != errLZ4Corrupt {
+			t.Fatalf("got %v, want %v", err, errLZ4Corrupt)
+		}
+	}
+
+	for _, off := range []byte{0, 2} {
+		data := []byte{5, 0, 0, 0, 0x10, 'a', off, 0}
+		if _, err := lz4Decode(data, nil); err != errLZ4Corrupt {
+			t.Fatalf("offset %d: got %v, want %v", off, err, errLZ4Corrupt)
+		}
+	}
+	if dec, err := lz4Decode([]byte{5, 0, 0, 0, 0x10, 'a', 1, 0}, nil); err != nil || string(dec) != "aaaaa" {
+		t.Fatalf("overlapping match: got %q, %v", dec, err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		mut := append([]byte(nil), enc...)
+		for j := 0; j < 1+rnd.Intn(4); j++ {
+			mut[rnd.Intn(len(mut))] = byte(rnd.Intn(256))
+		}
+		lz4Decode(mut, nil)
+	}
+}
+
+func TestLZ4SizeHeader(t *testing.T) {
+	data := binary.LittleEndian.AppendUint32(nil, 1<<31)
+	data = append(data, 0xf0, 255, 255, 0)
+	if _, err := lz4Decode(data, nil); err != errLZ4Corrupt {
+		t.Fatalf("got %v, want %v", err, errLZ4Corrupt)
+	}
+}
+
+const lz4Program = `package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+func reader(r io.Reader, dict []byte) (io.ReadCloser, error) {
+	%s
+}
+
+func main() {
+	for _, name := range os.Args[1:] {
+		data, err := os.ReadFile(name + ".lz4")
+		if err != nil {
+			panic(err)
+		}
+		dict, err := os.ReadFile(name + ".dict")
+		if err != nil {
+			panic(err)
+		}
+		r, err := reader(bytes.NewReader(data), dict)
+		if err != nil {
+			data, name = []byte(err.Error()), name+".err"
+		} else if data, err = io.ReadAll(r); err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(name+".out", data, 0o644); err != nil {
+			panic(err)
+		}
+	}
+}
+`
+
+func TestLZ4Decoder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program with the go tool")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", []byte("module lz4decoder\n\ngo 1.20\n"))
+	write("main.go", []byte(fmt.Sprintf(lz4Program, lz4Decoder)))
+
+	type lz4Case struct {
+		name       string
+		data, dict []byte
+	}
+	var cases []lz4Case
+	dict := []byte(strings.Repeat("resources into Go sources\n", 10))
+	for _, data := range testData() {
+		for _, depth := range []int{0, 1, 4, 16} {
+			for _, d := range [][]byte{nil, dict} {
+				cases = append(cases, lz4Case{fmt.Sprintf("case%d", len(cases)), lz4Encode(data, d, depth), d})
+			}
+		}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	enc := lz4Encode(testData()["text"], dict, 4)
+	for i := 0; i < 200; i++ {
+		mut := append([]byte(nil), enc...)
+		for j := 0; j < 1+rnd.Intn(4); j++ {
+			mut[rnd.Intn(len(mut))] = byte(rnd.Intn(256))
+		}
+		cases = append(cases, lz4Case{fmt.Sprintf("case%d", len(cases)), mut, dict})
+	}
+	args := []string{"run", "."}
+	for _, c := range cases {
+		write(c.name+".lz4", c.data)
+		write(c.name+".dict", c.dict)
+		args = append(args, c.name)
+	}
+	cmd := exec.Command(gobin, args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+
+	for _, c := range cases {
+		want, werr := lz4Decode(c.data, c.dict)
+		got, err := os.ReadFile(filepath.Join(dir, c.name+".out"))
+		if werr != nil {
+			if _, err := os.Stat(filepath.Join(dir, c.name+".err.out")); err != nil {
+				t.Fatalf("%s: generated decoder accepted input lz4Decode rejects: %v", c.name, werr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: generated decoder output differs from lz4Decode", c.name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package lib
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"math/rand"
+	"strings"
+	"unicode"
+)
+
+type shard struct {
+	Var  string
+	Func string
+	Data string
+	Mask string
+}
+
+func privateName(seen map[string]bool) string {
+	for {
+		s := StrGen(8)
+		if !unicode.IsUpper(rune(s[0])) && !seen[s] {
+			seen[s] = true
+			return s
+		}
+	}
+}
+
+func byteList(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("0x%02x", c)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func obfuscateKey(key []byte, seen map[string]bool) ([]shard, []shard) {
+	count := 4 + rand.Intn(4)
+	if count > len(key) {
+		count = len(key)
+	}
+	shards := make([]shard, 0, count)
+	for i := 0; i < count; i++ {
+		chunk := key[i*len(key)/count : (i+1)*len(key)/count]
+		mask := make([]byte, len(chunk))
+		_, _ = crand.Read(mask)
+		data := make([]byte, len(chunk))
+		for j := range chunk {
+			data[j] = chunk[j] ^ mask[j]
+		}
+		shards = append(shards, shard{
+			Var:  privateName(seen),
+			Func: privateName(seen),
+			Data: byteList(data),
+			Mask: byteList(mask),
+		})
+	}
+	decls := append([]shard(nil), shards...)
+	rand.Shuffle(len(decls), func(i, j int) { decls[i], decls[j] = decls[j], decls[i] })
+	return shards, decls
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+)
+
+var (
+	errSigningKey = errors.New("signing key must be a PEM encoded PKCS #8 or hex encoded Ed25519 key")
+	errSignature  = errors.New("invalid Ed25519 signature")
+)
+
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if priv, ok := key.(ed25519.PrivateKey); ok {
+			return priv, nil
+		}
+		return nil, errSigningKey
+	}
+	raw, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, errSigningKey
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, errSigningKey
+}
+
+func Sign(data []byte, key ed25519.PrivateKey) []byte {
+	return append(data, ed25519.Sign(key, data)...)
+}
+
+func VerifySignature(data []byte, pub ed25519.PublicKey) ([]byte, error) {
+	n := len(data) - ed25519.SignatureSize
+	if n < 0 || !ed25519.Verify(pub, data[:n], data[n:]) {
+		return nil, errSignature
+	}
+	return data[:n], nil
+}
@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSigningKey(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, ed25519.SeedSize)
+	priv := ed25519.NewKeyFromSeed(seed)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"key.pem":  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		"seed.hex": []byte(hex.EncodeToString(seed) + "\n"),
+		"key.hex":  []byte(hex.EncodeToString(priv)),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadSigningKey(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !priv.Equal(got) {
+			t.Fatalf("%s: loaded a different key", name)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"short.hex": []byte("0707"),
+		"text.txt":  []byte("not a key"),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSigningKey(path); err != errSigningKey {
+			t.Fatalf("%s: got %v, want %v", name, err, errSigningKey)
+		}
+	}
+	if _, err := LoadSigningKey(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("loaded a missing key file")
+	}
+}
+
+func TestSign(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{7}, ed25519.SeedSize))
+	pub := priv.Public().(ed25519.PublicKey)
+	data := []byte("compressembed")
+	signed := Sign(append([]byte(nil), data...), priv)
+	got, err := VerifySignature(signed, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+
+	signed[0] ^= 1
+	if _, err := VerifySignature(signed, pub); err != errSignature {
+		t.Fatalf("tampered: got %v, want %v", err, errSignature)
+	}
+	if _, err := VerifySignature(data, pub); err != errSignature {
+		t.Fatalf("unsigned: got %v, want %v", err, errSignature)
+	}
+}
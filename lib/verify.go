@@ -0,0 +1,410 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lecuong04/compressembed/lib/archive"
+)
+
+func header(src []byte) map[string]string {
+	fields := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		if sc.Text() == "" || sc.Text() == "//" || constraint.IsGoBuild(sc.Text()) {
+			continue
+		}
+		line, ok := strings.CutPrefix(sc.Text(), "// ")
+		if !ok {
+			break
+		}
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+func parseResource(v string) (out string, offset, end int, digest string, err error) {
+	i := strings.LastIndex(v, "[")
+	j := strings.LastIndex(v, "] SHA-256 ")
+	if i < 0 || j < i {
+		return "", 0, 0, "", fmt.Errorf("malformed resource record %q", v)
+	}
+	if _, err = fmt.Sscanf(v[i+1:j], "%d:%d", &offset, &end); err != nil {
+		return "", 0, 0, "", fmt.Errorf("malformed resource record %q", v)
+	}
+	return v[:i], offset, end, v[j+len("] SHA-256 "):], nil
+}
+
+func literalKey(src []byte) (string, bool) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return "", false
+	}
+	var keys []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 2 {
+			return true
+		}
+		call, ok := ret.Results[0].(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		if t, ok := call.Fun.(*ast.ArrayType); !ok || t.Len != nil || fmt.Sprint(t.Elt) != "byte" {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		if id, ok := ret.Results[1].(*ast.Ident); !ok || id.Name != "nil" {
+			return true
+		}
+		if key, err := strconv.Unquote(lit.Value); err == nil {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if len(keys) != 1 {
+		return "", false
+	}
+	return keys[0], true
+}
+
+func byteLiteral(e ast.Expr) ([]byte, bool) {
+	lit, ok := e.(*ast.CompositeLit)
+	if !ok {
+		return nil, false
+	}
+	b := make([]byte, 0, len(lit.Elts))
+	for _, elt := range lit.Elts {
+		v, ok := elt.(*ast.BasicLit)
+		if !ok || v.Kind != token.INT {
+			return nil, false
+		}
+		n, err := strconv.ParseUint(v.Value, 0, 8)
+		if err != nil {
+			return nil, false
+		}
+		b = append(b, byte(n))
+	}
+	return b, true
+}
+
+// obfuscatedKey reassembles a key split by -obfuscate from the
+// append(key, unmask(data, mask())...) calls of the generated key func.
+func obfuscatedKey(src []byte) (string, bool) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return "", false
+	}
+	data, masks := map[string][]byte{}, map[string][]byte{}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if vs, ok := spec.(*ast.ValueSpec); ok && len(vs.Names) == 1 && len(vs.Values) == 1 {
+					if b, ok := byteLiteral(vs.Values[0]); ok {
+						data[vs.Names[0].Name] = b
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Body == nil || len(d.Body.List) != 1 {
+				continue
+			}
+			if ret, ok := d.Body.List[0].(*ast.ReturnStmt); ok && len(ret.Results) == 1 {
+				if b, ok := byteLiteral(ret.Results[0]); ok {
+					masks[d.Name.Name] = b
+				}
+			}
+		}
+	}
+
+	var key []byte
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		if fn, ok := call.Fun.(*ast.Ident); !ok || fn.Name != "append" {
+			return true
+		}
+		unmask, ok := call.Args[1].(*ast.CallExpr)
+		if !ok || len(unmask.Args) != 2 {
+			return true
+		}
+		v, ok := unmask.Args[0].(*ast.Ident)
+		if !ok {
+			return true
+		}
+		m, ok := unmask.Args[1].(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		fn, ok := m.Fun.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		shard, mask := data[v.Name], masks[fn.Name]
+		if shard == nil || len(shard) != len(mask) {
+			return true
+		}
+		for i := range shard {
+			key = append(key, shard[i]^mask[i])
+		}
+		return true
+	})
+	return string(key), len(key) > 0
+}
+
+func inlineData(src, name string) ([]byte, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), src, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	obj := f.Scope.Lookup(name)
+	if obj == nil || obj.Kind != ast.Var {
+		return nil, fmt.Errorf("%s has no inline data %s", src, name)
+	}
+	spec, ok := obj.Decl.(*ast.ValueSpec)
+	if !ok || len(spec.Values) != 1 {
+		return nil, fmt.Errorf("%s has malformed inline data %s", src, name)
+	}
+	var data []byte
+	ast.Inspect(spec.Values[0], func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			s, uerr := strconv.Unquote(lit.Value)
+			if uerr != nil {
+				err = uerr
+			}
+			data = append(data, s...)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s has malformed inline data %s: %w", src, name, err)
+	}
+	return data, nil
+}
+
+type verifier struct {
+	cfg   Config
+	codec string
+	dict  []byte
+	pub   ed25519.PublicKey
+	files map[string][]byte
+}
+
+func Verify(cfg Config) error {
+	for i, g := range groupEmbeds(cfg.embeds(), nil) {
+		if err := verifyGroup(cfg.group(i, g), g.Embeds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyGroup(cfg Config, embeds []Embed) error {
+	src, err := os.ReadFile(cfg.Src)
+	if err != nil {
+		return err
+	}
+	fields := header(src)
+
+	v := verifier{cfg: cfg, files: map[string][]byte{}}
+	v.codec, _, _ = strings.Cut(fields["Codec"], ", level: ")
+	if v.codec == "" {
+		v.codec = cfg.Codec
+	}
+	if signer, ok := fields["Ed25519"]; ok {
+		v.pub, err = hex.DecodeString(signer)
+		if err != nil || len(v.pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("%s has a malformed Ed25519 public key", cfg.Src)
+		}
+	}
+
+	kind, arg, err := ParseKeySource(cfg.KeySource)
+	if err != nil {
+		return err
+	}
+	if key, ok := LoadKey(kind, arg); ok {
+		v.cfg.Key = key
+	} else if v.cfg.Key == "" && (cfg.Dict == "" || cfg.Encrypt) {
+		if kind == KeyLiteral {
+			if v.cfg.Key, ok = literalKey(src); !ok {
+				v.cfg.Key, ok = obfuscatedKey(src)
+			}
+		}
+		if !ok {
+			return fmt.Errorf("cannot recover the key of %s, pass -key or -key-source", cfg.Src)
+		}
+	}
+
+	if v.dict, err = v.cfg.dictionary(); err != nil {
+		return err
+	}
+	if record, ok := fields["Dictionary"]; ok {
+		if cfg.Dict == "" {
+			return fmt.Errorf("%s was generated with a dictionary, -dict is required", cfg.Src)
+		}
+		if err := v.checkDictionary(record); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range embeds {
+		record, ok := fields["Resource "+e.Var]
+		if !ok {
+			return fmt.Errorf("%s has no recorded resource %s", cfg.Src, e.Var)
+		}
+		if IsArchiveInput(e.Input) {
+			content, ok := fields["Archive "+e.Var]
+			if !ok {
+				return fmt.Errorf("%s has no recorded archive content for %s", cfg.Src, e.Var)
+			}
+			err = v.checkArchive(e, record, content)
+		} else {
+			err = v.check(e, record)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *verifier) unseal(name, output string, offset, end int) ([]byte, error) {
+	file, ok := v.files[output]
+	if !ok {
+		var err error
+		if v.cfg.Inline {
+			file, err = inlineData(v.cfg.Src, output)
+		} else {
+			file, err = os.ReadFile(output)
+		}
+		if err != nil {
+			return nil, err
+		}
+		v.files[output] = file
+	}
+	if offset < 0 || offset > end || end > len(file) {
+		return nil, fmt.Errorf("%s is truncated: %s needs bytes %d to %d", output, name, offset, end)
+	}
+	payload := file[offset:end]
+
+	var err error
+	if v.pub != nil {
+		payload, err = VerifySignature(payload, v.pub)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", output, name, err)
+		}
+	}
+	if v.cfg.Encrypt {
+		payload, err = Decrypt(payload, []byte(v.cfg.Key))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", output, name, err)
+		}
+	}
+	return payload, nil
+}
+
+func (v *verifier) checkDictionary(record string) error {
+	output, offset, end, digest, err := parseResource(record)
+	if err != nil {
+		return err
+	}
+	want := Digest(v.dict)
+	if digest != want {
+		return fmt.Errorf("%s is stale: recorded dictionary SHA-256 %s, %s has %s", v.cfg.Src, digest, v.cfg.Dict, want)
+	}
+	payload, err := v.unseal("dictionary", output, offset, end)
+	if err != nil {
+		return err
+	}
+	if got := Digest(payload); got != want {
+		return fmt.Errorf("%s is corrupt: dictionary SHA-256 %s, want %s", output, got, want)
+	}
+	return nil
+}
+
+func (v *verifier) check(e Embed, record string) error {
+	output, offset, end, digest, err := parseResource(record)
+	if err != nil {
+		return err
+	}
+
+	data, err := ReadInput(e.Input)
+	if err != nil {
+		return err
+	}
+	want := Digest(data)
+	if digest != want {
+		return fmt.Errorf("%s is stale: recorded SHA-256 %s for %s, %s has %s", v.cfg.Src, digest, e.Var, e.Input, want)
+	}
+
+	payload, err := v.unseal(e.Var, output, offset, end)
+	if err != nil {
+		return err
+	}
+	out, err := Decompress(v.codec, payload, v.dict)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", output, e.Var, err)
+	}
+	if got := Digest(out); got != want {
+		return fmt.Errorf("%s is corrupt: SHA-256 %s for %s, want %s", output, got, e.Var, want)
+	}
+	return nil
+}
+
+func (v *verifier) checkArchive(e Embed, record, archived string) error {
+	output, offset, end, digest, err := parseResource(record)
+	if err != nil {
+		return err
+	}
+	_, content, ok := strings.Cut(archived, "content SHA-256 ")
+	if !ok {
+		return fmt.Errorf("malformed archive record %q", archived)
+	}
+
+	w := &archive.Writer{}
+	if _, err := archive.Pack(e.Input, w); err != nil {
+		return err
+	}
+	if want := ContentDigest(w.Entries()); content != want {
+		return fmt.Errorf("%s is stale: recorded content SHA-256 %s for %s, %s has %s", v.cfg.Src, content, e.Var, e.Input, want)
+	}
+
+	payload, err := v.unseal(e.Var, output, offset, end)
+	if err != nil {
+		return err
+	}
+	if got := Digest(payload); got != digest {
+		return fmt.Errorf("%s is corrupt: SHA-256 %s for %s, want %s", output, got, e.Var, digest)
+	}
+	ar, err := NewArchiveReader(bytes.NewReader(payload), int64(len(payload)), v.dict)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", output, e.Var, err)
+	}
+	for _, entry := range ar.Entries {
+		if _, err := ar.ReadFile(entry); err != nil {
+			return fmt.Errorf("%s: %s: %s: %w", output, e.Var, entry.Path, err)
+		}
+	}
+	if got := ContentDigest(ar.Entries); got != content {
+		return fmt.Errorf("%s is corrupt: content SHA-256 %s for %s, want %s", output, got, e.Var, content)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		config func(cfg *Config)
+		input  string
+	}{
+		{"plain", func(cfg *Config) {}, "data.txt"},
+		{"encrypt", func(cfg *Config) { cfg.Encrypt, cfg.Key = true, "secret" }, "data.txt"},
+		{"sign", func(cfg *Config) { cfg.Codec, cfg.SignKey = "zstd", "sign.hex" }, "data.txt"},
+		{"archive", func(cfg *Config) { cfg.Input = "web" }, "web/index.html"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"data.txt":       strings.Repeat("compressembed\n", 100),
+				"sign.hex":       strings.Repeat("07", 32),
+				"web/index.html": strings.Repeat("<p>hello</p>\n", 50),
+			})
+			chdir(t, dir)
+			cfg := baseConfig()
+			c.config(&cfg)
+			Run(cfg)
+			if err := Verify(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			dat, err := os.ReadFile(cfg.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dat[len(dat)/2] ^= 0xff
+			writeFiles(t, dir, map[string]string{cfg.Output: string(dat)})
+			if err := Verify(cfg); err == nil {
+				t.Fatal("verified a corrupted resource")
+			}
+
+			writeFiles(t, dir, map[string]string{c.input: "changed\n"})
+			if err := Verify(cfg); err == nil || !strings.Contains(err.Error(), "is stale") {
+				t.Fatalf("got %v, want a stale error", err)
+			}
+		})
+	}
+}
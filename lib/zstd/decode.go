@@ -0,0 +1,518 @@
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+type Reader struct {
+	r        io.Reader
+	dict     []byte
+	buf      []byte
+	lits     []byte
+	hist     []byte
+	out      []byte
+	window   int
+	blockMax int
+	inFrame  bool
+	checksum bool
+	size     int64
+	produced int64
+	hash     xxh64
+	rep      [3]uint32
+	huf      *hufTable
+	ll       *fseTable
+	of       *fseTable
+	ml       *fseTable
+	err      error
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	return NewReaderDict(r, nil)
+}
+
+func NewReaderDict(r io.Reader, dict []byte) (*Reader, error) {
+	d := &Reader{r: r, dict: dict}
+	if err := d.frameHeader(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+func Decode(src, dict []byte) ([]byte, error) {
+	d, err := NewReaderDict(bytes.NewReader(src), dict)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	return io.ReadAll(d)
+}
+
+func (d *Reader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+func (d *Reader) Close() error {
+	d.hist, d.buf, d.lits, d.out = nil, nil, nil, nil
+	if d.err == nil {
+		d.err = ErrClosed
+	}
+	return nil
+}
+
+func (d *Reader) readFull(n int) ([]byte, error) {
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+	d.buf = d.buf[:n]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return d.buf, nil
+}
+
+func (d *Reader) frameHeader() error {
+	for {
+		var m [4]byte
+		if _, err := io.ReadFull(d.r, m[:]); err != nil {
+			return err
+		}
+		id := binary.LittleEndian.Uint32(m[:])
+		if id&skippableMask == skippableId {
+			b, err := d.readFull(4)
+			if err != nil {
+				return err
+			}
+			if _, err = io.CopyN(io.Discard, d.r, int64(binary.LittleEndian.Uint32(b))); err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			continue
+		}
+		if id != magic {
+			return ErrMagic
+		}
+		break
+	}
+	b, err := d.readFull(1)
+	if err != nil {
+		return err
+	}
+	desc := b[0]
+	if desc&0x08 != 0 {
+		return ErrCorrupt
+	}
+	single := desc&0x20 != 0
+	fcsSize := [4]int{0, 2, 4, 8}[desc>>6]
+	if fcsSize == 0 && single {
+		fcsSize = 1
+	}
+	n := [4]int{0, 1, 2, 4}[desc&3] + fcsSize
+	if !single {
+		n++
+	}
+	if b, err = d.readFull(n); err != nil {
+		return err
+	}
+	window := 0
+	if !single {
+		exp := int(b[0] >> 3)
+		if exp+minWindowLog > maxWindowLog {
+			return ErrWindow
+		}
+		base := 1 << (minWindowLog + exp)
+		window = base + base/8*int(b[0]&7)
+	}
+	d.size = -1
+	if fcsSize > 0 {
+		var fcs [8]byte
+		copy(fcs[:], b[n-fcsSize:])
+		d.size = int64(binary.LittleEndian.Uint64(fcs[:]))
+		if fcsSize == 2 {
+			d.size += 256
+		}
+		if d.size < 0 {
+			return ErrCorrupt
+		}
+	}
+	if single {
+		if d.size > 1<<maxWindowLog {
+			return ErrWindow
+		}
+		window = int(d.size)
+	}
+	d.window = window
+	d.blockMax = window
+	if d.blockMax > maxBlockSize {
+		d.blockMax = maxBlockSize
+	}
+	d.checksum = desc&0x04 != 0
+	d.inFrame = true
+	d.produced = 0
+	d.hash.reset()
+	d.rep = [3]uint32{1, 4, 8}
+	d.huf, d.ll, d.of, d.ml = nil, nil, nil, nil
+	d.hist = append(d.hist[:0], d.dict...)
+	return nil
+}
+
+func (d *Reader) next() error {
+	if !d.inFrame {
+		if err := d.frameHeader(); err != nil {
+			return err
+		}
+	}
+	keep := d.window + len(d.dict)
+	if len(d.hist) > keep+4*maxBlockSize {
+		n := copy(d.hist, d.hist[len(d.hist)-keep:])
+		d.hist = d.hist[:n]
+	}
+	b, err := d.readFull(3)
+	if err != nil {
+		return err
+	}
+	h := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+	last := h&1 != 0
+	size := int(h >> 3)
+	start := len(d.hist)
+	switch (h >> 1) & 3 {
+	case blockRaw:
+		if size > d.blockMax {
+			return ErrCorrupt
+		}
+		if b, err = d.readFull(size); err != nil {
+			return err
+		}
+		d.hist = append(d.hist, b...)
+	case blockRLE:
+		if size > d.blockMax {
+			return ErrCorrupt
+		}
+		if b, err = d.readFull(1); err != nil {
+			return err
+		}
+		d.hist = append(d.hist, bytes.Repeat(b[:1], size)...)
+	case blockCompressed:
+		if size > d.blockMax {
+			return ErrCorrupt
+		}
+		if b, err = d.readFull(size); err != nil {
+			return err
+		}
+		if err = d.compressedBlock(b); err != nil {
+			return err
+		}
+		if len(d.hist)-start > d.blockMax {
+			return ErrCorrupt
+		}
+	default:
+		return ErrCorrupt
+	}
+	d.out = d.hist[start:]
+	d.produced += int64(len(d.out))
+	d.hash.Write(d.out)
+	if last {
+		d.inFrame = false
+		if d.checksum {
+			if b, err = d.readFull(4); err != nil {
+				return err
+			}
+			if binary.LittleEndian.Uint32(b) != uint32(d.hash.Sum64()) {
+				return ErrChecksum
+			}
+		}
+		if d.size >= 0 && d.size != d.produced {
+			return ErrCorrupt
+		}
+	}
+	return nil
+}
+
+func (d *Reader) compressedBlock(in []byte) error {
+	lits, n, err := d.literals(in)
+	if err != nil {
+		return err
+	}
+	in = in[n:]
+	if len(in) == 0 {
+		return ErrCorrupt
+	}
+	nbSeq := int(in[0])
+	switch {
+	case nbSeq == 0:
+		if len(in) != 1 {
+			return ErrCorrupt
+		}
+		d.hist = append(d.hist, lits...)
+		return nil
+	case nbSeq < 128:
+		in = in[1:]
+	case nbSeq < 255:
+		if len(in) < 2 {
+			return ErrCorrupt
+		}
+		nbSeq = (nbSeq-128)<<8 + int(in[1])
+		in = in[2:]
+	default:
+		if len(in) < 3 {
+			return ErrCorrupt
+		}
+		nbSeq = int(in[1]) + int(in[2])<<8 + 0x7F00
+		in = in[3:]
+	}
+	if len(in) == 0 || in[0]&3 != 0 {
+		return ErrCorrupt
+	}
+	modes := in[0]
+	in = in[1:]
+	if d.ll, n, err = d.table(modes>>6, d.ll, in, maxLLSymbol, maxLLLog, llPredefined); err != nil {
+		return err
+	}
+	in = in[n:]
+	if d.of, n, err = d.table(modes>>4&3, d.of, in, maxOFSymbol, maxOFLog, ofPredefined); err != nil {
+		return err
+	}
+	in = in[n:]
+	if d.ml, n, err = d.table(modes>>2&3, d.ml, in, maxMLSymbol, maxMLLog, mlPredefined); err != nil {
+		return err
+	}
+	in = in[n:]
+	return d.sequences(in, nbSeq, lits)
+}
+
+func (d *Reader) table(mode byte, prev *fseTable, in []byte, maxSym int, maxLog uint8, predefined *fseTable) (*fseTable, int, error) {
+	switch mode {
+	case modePredefined:
+		return predefined, 0, nil
+	case modeRLE:
+		if len(in) == 0 || int(in[0]) > maxSym {
+			return nil, 0, ErrCorrupt
+		}
+		return rleTable(in[0]), 1, nil
+	case modeCompressed:
+		norm, log, n, err := readNCount(in, maxSym, maxLog)
+		if err != nil {
+			return nil, 0, err
+		}
+		t, err := buildFSE(norm, log)
+		return t, n, err
+	default:
+		if prev == nil {
+			return nil, 0, ErrCorrupt
+		}
+		return prev, 0, nil
+	}
+}
+
+func (d *Reader) literals(in []byte) ([]byte, int, error) {
+	if len(in) == 0 {
+		return nil, 0, ErrCorrupt
+	}
+	typ := in[0] & 3
+	format := in[0] >> 2 & 3
+	if typ == litRaw || typ == litRLE {
+		var size, n int
+		switch format {
+		case 0, 2:
+			size, n = int(in[0]>>3), 1
+		case 1:
+			if len(in) < 2 {
+				return nil, 0, ErrCorrupt
+			}
+			size, n = int(in[0]>>4)+int(in[1])<<4, 2
+		default:
+			if len(in) < 3 {
+				return nil, 0, ErrCorrupt
+			}
+			size, n = int(in[0]>>4)+int(in[1])<<4+int(in[2])<<12, 3
+		}
+		if size > d.blockMax {
+			return nil, 0, ErrCorrupt
+		}
+		if typ == litRaw {
+			if n+size > len(in) {
+				return nil, 0, ErrCorrupt
+			}
+			return in[n : n+size], n + size, nil
+		}
+		if n >= len(in) {
+			return nil, 0, ErrCorrupt
+		}
+		d.lits = append(d.lits[:0], bytes.Repeat(in[n:n+1], size)...)
+		return d.lits, n + 1, nil
+	}
+
+	var regen, comp, n int
+	streams := 4
+	switch format {
+	case 0, 1:
+		if len(in) < 3 {
+			return nil, 0, ErrCorrupt
+		}
+		v := int(in[0]) | int(in[1])<<8 | int(in[2])<<16
+		regen, comp, n = v>>4&0x3FF, v>>14&0x3FF, 3
+		if format == 0 {
+			streams = 1
+		}
+	case 2:
+		if len(in) < 4 {
+			return nil, 0, ErrCorrupt
+		}
+		v := int(binary.LittleEndian.Uint32(in))
+		regen, comp, n = v>>4&0x3FFF, v>>18&0x3FFF, 4
+	default:
+		if len(in) < 5 {
+			return nil, 0, ErrCorrupt
+		}
+		v := int(binary.LittleEndian.Uint32(in)) | int(in[4])<<32
+		regen, comp, n = v>>4&0x3FFFF, v>>22&0x3FFFF, 5
+	}
+	if regen > d.blockMax || n+comp > len(in) {
+		return nil, 0, ErrCorrupt
+	}
+	src := in[n : n+comp]
+	if typ == litCompressed {
+		t, hn, err := readHuffman(src)
+		if err != nil {
+			return nil, 0, err
+		}
+		d.huf = t
+		src = src[hn:]
+	} else if d.huf == nil {
+		return nil, 0, ErrCorrupt
+	}
+	if cap(d.lits) < regen {
+		d.lits = make([]byte, regen)
+	}
+	d.lits = d.lits[:regen]
+	if streams == 1 {
+		if err := d.huf.decode(d.lits, src); err != nil {
+			return nil, 0, err
+		}
+		return d.lits, n + comp, nil
+	}
+	if len(src) < 6 {
+		return nil, 0, ErrCorrupt
+	}
+	seg := (regen + 3) / 4
+	if 3*seg > regen {
+		return nil, 0, ErrCorrupt
+	}
+	sizes := [4]int{
+		int(binary.LittleEndian.Uint16(src)),
+		int(binary.LittleEndian.Uint16(src[2:])),
+		int(binary.LittleEndian.Uint16(src[4:])),
+	}
+	src = src[6:]
+	sizes[3] = len(src) - sizes[0] - sizes[1] - sizes[2]
+	if sizes[3] < 0 {
+		return nil, 0, ErrCorrupt
+	}
+	for i := 0; i < 4; i++ {
+		lo := i * seg
+		hi := lo + seg
+		if i == 3 {
+			hi = regen
+		}
+		if err := d.huf.decode(d.lits[lo:hi], src[:sizes[i]]); err != nil {
+			return nil, 0, err
+		}
+		src = src[sizes[i]:]
+	}
+	return d.lits, n + comp, nil
+}
+
+func (d *Reader) sequences(in []byte, nbSeq int, lits []byte) error {
+	var br backwardBits
+	if err := br.init(in); err != nil {
+		return err
+	}
+	ll := uint16(br.read(d.ll.log))
+	of := uint16(br.read(d.of.log))
+	ml := uint16(br.read(d.ml.log))
+	for i := 0; i < nbSeq; i++ {
+		ofCode := d.of.e[of].sym
+		mlCode := d.ml.e[ml].sym
+		llCode := d.ll.e[ll].sym
+		if ofCode > maxOFSymbol || mlCode > maxMLSymbol || llCode > maxLLSymbol {
+			return ErrCorrupt
+		}
+		offset := uint32(1)<<ofCode + uint32(br.read(ofCode))
+		matchLen := mlBase[mlCode] + uint32(br.read(mlBits[mlCode]))
+		litLen := llBase[llCode] + uint32(br.read(llBits[llCode]))
+
+		if offset > 3 {
+			offset -= 3
+			d.rep = [3]uint32{offset, d.rep[0], d.rep[1]}
+		} else {
+			if litLen == 0 {
+				offset++
+			}
+			switch offset {
+			case 1:
+				offset = d.rep[0]
+			case 2:
+				offset = d.rep[1]
+				d.rep = [3]uint32{offset, d.rep[0], d.rep[2]}
+			case 3:
+				offset = d.rep[2]
+				d.rep = [3]uint32{offset, d.rep[0], d.rep[1]}
+			default:
+				offset = d.rep[0] - 1
+				if offset == 0 {
+					return ErrCorrupt
+				}
+				d.rep = [3]uint32{offset, d.rep[0], d.rep[1]}
+			}
+		}
+
+		if i < nbSeq-1 {
+			e := d.ll.e[ll]
+			ll = e.base + uint16(br.read(e.bits))
+			e = d.ml.e[ml]
+			ml = e.base + uint16(br.read(e.bits))
+			e = d.of.e[of]
+			of = e.base + uint16(br.read(e.bits))
+		}
+		if br.left < 0 {
+			return ErrCorrupt
+		}
+
+		if int(litLen) > len(lits) {
+			return ErrCorrupt
+		}
+		d.hist = append(d.hist, lits[:litLen]...)
+		lits = lits[litLen:]
+		if int(offset) > len(d.hist) {
+			return ErrCorrupt
+		}
+		start := len(d.hist) - int(offset)
+		for n := int(matchLen); n > 0; {
+			c := n
+			if c > len(d.hist)-start {
+				c = len(d.hist) - start
+			}
+			d.hist = append(d.hist, d.hist[start:start+c]...)
+			n -= c
+		}
+	}
+	if br.left != 0 {
+		return ErrCorrupt
+	}
+	d.hist = append(d.hist, lits...)
+	return nil
+}
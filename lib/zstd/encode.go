@@ -0,0 +1,439 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	HuffmanOnly   = -2
+	NoCompression = 0
+	MinLevel      = 1
+	MaxLevel      = 19
+	DefaultLevel  = 3
+
+	minMatch = 4
+	hashLog  = 17
+)
+
+type sequence struct {
+	litLen   uint32
+	matchLen uint32
+	offset   uint32
+}
+
+type matcher struct {
+	buf    []byte
+	head   []int32
+	chain  []int32
+	next   int
+	window int
+	depth  int
+}
+
+func hash4(b []byte) uint32 {
+	return (binary.LittleEndian.Uint32(b) * 2654435761) >> (32 - hashLog)
+}
+
+func (m *matcher) insert(end int) {
+	for ; m.next < end; m.next++ {
+		if m.next+minMatch > len(m.buf) {
+			continue
+		}
+		h := hash4(m.buf[m.next:])
+		m.chain[m.next] = m.head[h]
+		m.head[h] = int32(m.next + 1)
+	}
+}
+
+func (m *matcher) extend(a, b, end int) int {
+	n := 0
+	for b+n < end && m.buf[a+n] == m.buf[b+n] {
+		n++
+	}
+	return n
+}
+
+// gain weighs the literal bytes a match covers against the extra bits its
+// offset costs, so a short match at the repeat offset can beat a longer one
+// far back in the window.
+func gain(off, rep uint32, n int) int {
+	if off == rep {
+		return 4 * n
+	}
+	return 4*n - int(highBit(off+3))
+}
+
+// repCode returns the offset value coding off after litLen literals and the
+// repeat offsets that follow, mirroring the decoder's update rules.
+func repCode(rep [3]uint32, off, litLen uint32) (uint32, [3]uint32) {
+	cands := [4]uint32{rep[0], rep[1], rep[2], rep[0] - 1}
+	first := 0
+	if litLen == 0 {
+		first = 1
+	}
+	for i := first; i < first+3; i++ {
+		if off == 0 || cands[i] != off {
+			continue
+		}
+		v := uint32(i + 1 - first)
+		switch i {
+		case 0:
+			return v, rep
+		case 1:
+			return v, [3]uint32{off, rep[0], rep[2]}
+		}
+		return v, [3]uint32{off, rep[0], rep[1]}
+	}
+	return off + 3, [3]uint32{off, rep[0], rep[1]}
+}
+
+func (m *matcher) find(pos, end int, rep uint32) (uint32, int) {
+	m.insert(pos)
+	if pos+minMatch > end {
+		return 0, 0
+	}
+	var bestOff uint32
+	bestLen, best := 0, 0
+	if r := int(rep); r <= pos && r > 0 && r <= m.window {
+		if n := m.extend(pos-r, pos, end); n >= minMatch {
+			bestOff, bestLen, best = rep, n, gain(rep, rep, n)
+		}
+	}
+	// Offsets only grow along the chain, so a hit no longer than one already
+	// seen cannot have a better gain.
+	longest := bestLen
+	cand := int(m.head[hash4(m.buf[pos:])]) - 1
+	for i := 0; i < m.depth && cand >= 0 && pos-cand <= m.window && pos+longest < end; i++ {
+		if m.buf[cand+longest] == m.buf[pos+longest] {
+			if n := m.extend(cand, pos, end); n > longest {
+				longest = n
+				if off := uint32(pos - cand); gain(off, rep, n) > best {
+					bestOff, bestLen, best = off, n, gain(off, rep, n)
+				}
+			}
+		}
+		cand = int(m.chain[cand]) - 1
+	}
+	return bestOff, bestLen
+}
+
+type encoder struct {
+	m   matcher
+	raw bool
+	rep [3]uint32
+	out []byte
+}
+
+func Encode(src, dict []byte, level int) []byte {
+	if level < MinLevel && level != NoCompression && level != HuffmanOnly {
+		level = DefaultLevel
+	}
+	if level > MaxLevel {
+		level = MaxLevel
+	}
+	depth := 0
+	if level >= MinLevel {
+		depth = 1 << (level / 2)
+	}
+	buf := make([]byte, 0, len(dict)+len(src))
+	buf = append(append(buf, dict...), src...)
+
+	windowLog := minWindowLog
+	for windowLog < 23 && 1<<windowLog < len(buf) {
+		windowLog++
+	}
+	e := &encoder{
+		m:   matcher{buf: buf, window: 1 << windowLog, depth: depth},
+		raw: level == NoCompression,
+		rep: [3]uint32{1, 4, 8},
+	}
+	if depth > 0 {
+		e.m.head = make([]int32, 1<<hashLog)
+		e.m.chain = make([]int32, len(buf))
+		e.m.insert(len(dict))
+	}
+
+	out := binary.LittleEndian.AppendUint32(nil, magic)
+	size := uint64(len(src))
+	switch {
+	case size >= 256 && size < 65536+256:
+		out = append(out, 1<<6|0x04, byte((windowLog-minWindowLog)<<3))
+		out = binary.LittleEndian.AppendUint16(out, uint16(size-256))
+	case size <= math.MaxUint32:
+		out = append(out, 2<<6|0x04, byte((windowLog-minWindowLog)<<3))
+		out = binary.LittleEndian.AppendUint32(out, uint32(size))
+	default:
+		out = append(out, 3<<6|0x04, byte((windowLog-minWindowLog)<<3))
+		out = binary.LittleEndian.AppendUint64(out, size)
+	}
+	e.out = out
+
+	start := len(dict)
+	if start == len(buf) {
+		e.out = append(e.out, 1, 0, 0)
+	}
+	for start < len(buf) {
+		end := start + maxBlockSize
+		if end > len(buf) {
+			end = len(buf)
+		}
+		e.block(start, end, end == len(buf))
+		start = end
+	}
+
+	var h xxh64
+	h.reset()
+	h.Write(src)
+	return binary.LittleEndian.AppendUint32(e.out, uint32(h.Sum64()))
+}
+
+func (e *encoder) parse(start, end int) ([]sequence, []byte) {
+	if e.m.depth == 0 {
+		return nil, e.m.buf[start:end]
+	}
+	var seqs []sequence
+	var lits []byte
+	rep := e.rep
+	anchor := start
+	for pos := start; pos+minMatch <= end; {
+		off, n := e.m.find(pos, end, rep[0])
+		if n < minMatch {
+			pos++
+			continue
+		}
+		for pos+1+minMatch <= end {
+			off2, n2 := e.m.find(pos+1, end, rep[0])
+			if n2 < minMatch || gain(off2, rep[0], n2) <= gain(off, rep[0], n)+4 {
+				break
+			}
+			pos++
+			off, n = off2, n2
+		}
+		seqs = append(seqs, sequence{litLen: uint32(pos - anchor), matchLen: uint32(n), offset: off})
+		lits = append(lits, e.m.buf[anchor:pos]...)
+		_, rep = repCode(rep, off, uint32(pos-anchor))
+		pos += n
+		anchor = pos
+	}
+	lits = append(lits, e.m.buf[anchor:end]...)
+	e.m.insert(end)
+	return seqs, lits
+}
+
+func (e *encoder) block(start, end int, last bool) {
+	raw := e.m.buf[start:end]
+	var body []byte
+	rep := e.rep
+	if !e.raw {
+		body = e.compress(start, end)
+	}
+	var h uint32
+	if last {
+		h = 1
+	}
+	if body == nil || len(body) >= len(raw) {
+		e.rep = rep
+		h |= uint32(len(raw))<<3 | blockRaw<<1
+		e.out = append(e.out, byte(h), byte(h>>8), byte(h>>16))
+		e.out = append(e.out, raw...)
+		return
+	}
+	h |= uint32(len(body))<<3 | blockCompressed<<1
+	e.out = append(e.out, byte(h), byte(h>>8), byte(h>>16))
+	e.out = append(e.out, body...)
+}
+
+// compress parses the block at each search depth up to the level's own and
+// keeps the smallest encoding, so a deeper search never loses to a shallower
+// one that happens to land on cheaper offsets.
+func (e *encoder) compress(start, end int) []byte {
+	depth, rep, next := e.m.depth, e.rep, e.m.next
+	if depth == 0 {
+		return e.compressBlock(e.parse(start, end))
+	}
+	head := append([]int32(nil), e.m.head...)
+	var best []byte
+	var bestRep [3]uint32
+	for d := 1; d <= depth; d *= 2 {
+		if d > 1 {
+			copy(e.m.head, head)
+			e.m.next, e.rep = next, rep
+		}
+		e.m.depth = d
+		if body := e.compressBlock(e.parse(start, end)); best == nil || len(body) < len(best) {
+			best, bestRep = body, e.rep
+		}
+	}
+	e.m.depth, e.rep = depth, bestRep
+	return best
+}
+
+func literalHeader(dst []byte, typ byte, size int) []byte {
+	switch {
+	case size < 32:
+		return append(dst, typ|byte(size)<<3)
+	case size < 4096:
+		return append(dst, typ|1<<2|byte(size)<<4, byte(size>>4))
+	default:
+		return append(dst, typ|3<<2|byte(size)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+func encodeLiterals(dst, lits []byte) []byte {
+	if len(lits) > 0 {
+		same := true
+		for _, b := range lits {
+			if b != lits[0] {
+				same = false
+				break
+			}
+		}
+		if same && len(lits) > 2 {
+			return append(literalHeader(dst, litRLE, len(lits)), lits[0])
+		}
+	}
+	if len(lits) >= 32 {
+		if comp, single := compressLiterals(lits); comp != nil && len(comp)+5 < len(lits) {
+			regen, size := uint64(len(lits)), uint64(len(comp))
+			switch {
+			case single:
+				v := litCompressed | regen<<4 | size<<14
+				dst = append(dst, byte(v), byte(v>>8), byte(v>>16))
+			case regen < 1024 && size < 1024:
+				v := litCompressed | 1<<2 | regen<<4 | size<<14
+				dst = append(dst, byte(v), byte(v>>8), byte(v>>16))
+			case regen < 16384 && size < 16384:
+				v := litCompressed | 2<<2 | regen<<4 | size<<18
+				dst = binary.LittleEndian.AppendUint32(dst, uint32(v))
+			default:
+				v := litCompressed | 3<<2 | regen<<4 | size<<22
+				dst = binary.LittleEndian.AppendUint32(dst, uint32(v))
+				dst = append(dst, byte(v>>32))
+			}
+			return append(dst, comp...)
+		}
+	}
+	return append(literalHeader(dst, litRaw, len(lits)), lits...)
+}
+
+type seqField struct {
+	codes    []uint8
+	maxSym   int
+	maxLog   uint8
+	defNorm  []int16
+	defLog   uint8
+	mode     byte
+	enc      *fseEncoder
+	rleValue uint8
+}
+
+func (f *seqField) choose(dst []byte) []byte {
+	counts := make([]int, f.maxSym+1)
+	distinct := 0
+	for _, c := range f.codes {
+		if counts[c] == 0 {
+			distinct++
+		}
+		counts[c]++
+	}
+	if distinct == 1 && len(f.codes) > 2 {
+		f.mode = modeRLE
+		f.rleValue = f.codes[0]
+		return append(dst, f.codes[0])
+	}
+	f.mode = modePredefined
+	best := fseCost(counts, f.defNorm, f.defLog)
+	log := tableLog(len(f.codes), distinct, f.maxLog)
+	norm := normalize(counts, len(f.codes), log)
+	last := len(norm) - 1
+	for norm[last] == 0 {
+		last--
+	}
+	norm = norm[:last+1]
+	header := writeNCount(nil, norm, log)
+	if cost := fseCost(counts, norm, log) + float64(8*len(header)); cost < best {
+		f.mode = modeCompressed
+		f.enc = buildFSEEncoder(norm, log)
+		return append(dst, header...)
+	}
+	f.enc = buildFSEEncoder(f.defNorm, f.defLog)
+	return dst
+}
+
+func (e *encoder) compressBlock(seqs []sequence, lits []byte) []byte {
+	out := encodeLiterals(nil, lits)
+	n := len(seqs)
+	switch {
+	case n == 0:
+		return append(out, 0)
+	case n < 128:
+		out = append(out, byte(n))
+	case n < 0x7F00:
+		out = append(out, byte(n>>8)+128, byte(n))
+	default:
+		out = append(out, 255, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+
+	ll := seqField{codes: make([]uint8, n), maxSym: maxLLSymbol, maxLog: maxLLLog, defNorm: llDefault, defLog: llDefaultLog}
+	of := seqField{codes: make([]uint8, n), maxSym: maxOFSymbol, maxLog: maxOFLog, defNorm: ofDefault, defLog: ofDefaultLog}
+	ml := seqField{codes: make([]uint8, n), maxSym: maxMLSymbol, maxLog: maxMLLog, defNorm: mlDefault, defLog: mlDefaultLog}
+	offsets := make([]uint32, n)
+	for i, s := range seqs {
+		var off uint32
+		off, e.rep = repCode(e.rep, s.offset, s.litLen)
+		offsets[i] = off
+		ll.codes[i] = llCode(s.litLen)
+		ml.codes[i] = mlCode(s.matchLen)
+		of.codes[i] = highBit(off)
+	}
+
+	modes := len(out)
+	out = append(out, 0)
+	out = ll.choose(out)
+	out = of.choose(out)
+	out = ml.choose(out)
+	out[modes] = ll.mode<<6 | of.mode<<4 | ml.mode<<2
+
+	var bw bitWriter
+	bw.out = out
+	var sll, sof, sml fseState
+	put := func(i int) {
+		s := seqs[i]
+		bw.add(uint64(s.litLen-llBase[ll.codes[i]]), llBits[ll.codes[i]])
+		bw.add(uint64(s.matchLen-mlBase[ml.codes[i]]), mlBits[ml.codes[i]])
+		bw.add(uint64(offsets[i]-1<<of.codes[i]), of.codes[i])
+	}
+	if ml.mode != modeRLE {
+		sml.init(ml.enc, ml.codes[n-1])
+	}
+	if of.mode != modeRLE {
+		sof.init(of.enc, of.codes[n-1])
+	}
+	if ll.mode != modeRLE {
+		sll.init(ll.enc, ll.codes[n-1])
+	}
+	put(n - 1)
+	for i := n - 2; i >= 0; i-- {
+		if of.mode != modeRLE {
+			sof.encode(&bw, of.codes[i])
+		}
+		if ml.mode != modeRLE {
+			sml.encode(&bw, ml.codes[i])
+		}
+		if ll.mode != modeRLE {
+			sll.encode(&bw, ll.codes[i])
+		}
+		put(i)
+	}
+	if ml.mode != modeRLE {
+		sml.flush(&bw)
+	}
+	if of.mode != modeRLE {
+		sof.flush(&bw)
+	}
+	if ll.mode != modeRLE {
+		sll.flush(&bw)
+	}
+	return bw.close()
+}
@@ -0,0 +1,331 @@
+package zstd
+
+import "math"
+
+type fseEntry struct {
+	sym  uint8
+	bits uint8
+	base uint16
+}
+
+type fseTable struct {
+	log uint8
+	e   []fseEntry
+}
+
+func spread(norm []int16, log uint8) ([]uint8, int) {
+	size := 1 << log
+	symbols := make([]uint8, size)
+	high := size - 1
+	for s, n := range norm {
+		if n == -1 {
+			symbols[high] = uint8(s)
+			high--
+		}
+	}
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+	pos := 0
+	for s, n := range norm {
+		for i := 0; i < int(n); i++ {
+			symbols[pos] = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	return symbols, pos
+}
+
+func buildFSE(norm []int16, log uint8) (*fseTable, error) {
+	size := 1 << log
+	symbols, pos := spread(norm, log)
+	if pos != 0 {
+		return nil, ErrCorrupt
+	}
+	next := make([]uint32, len(norm))
+	for s, n := range norm {
+		if n == -1 {
+			next[s] = 1
+		} else {
+			next[s] = uint32(n)
+		}
+	}
+	t := &fseTable{log: log, e: make([]fseEntry, size)}
+	for u, s := range symbols {
+		ns := next[s]
+		next[s]++
+		nb := log - highBit(ns)
+		t.e[u] = fseEntry{sym: s, bits: nb, base: uint16(ns<<nb) - uint16(size)}
+	}
+	return t, nil
+}
+
+func rleTable(sym uint8) *fseTable {
+	return &fseTable{e: []fseEntry{{sym: sym}}}
+}
+
+func mustFSE(norm []int16, log uint8) *fseTable {
+	t, err := buildFSE(norm, log)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+var (
+	llPredefined = mustFSE(llDefault, llDefaultLog)
+	mlPredefined = mustFSE(mlDefault, mlDefaultLog)
+	ofPredefined = mustFSE(ofDefault, ofDefaultLog)
+)
+
+func readNCount(in []byte, maxSym int, maxLog uint8) ([]int16, uint8, int, error) {
+	if len(in) == 0 {
+		return nil, 0, 0, ErrCorrupt
+	}
+	br := forwardBits{in: in}
+	log := uint8(br.read(4)) + 5
+	if log > maxLog {
+		return nil, 0, 0, ErrCorrupt
+	}
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	nbBits := int(log) + 1
+	norm := make([]int16, maxSym+1)
+	sym := 0
+	prev0 := false
+	for remaining > 1 && sym <= maxSym {
+		if prev0 {
+			for {
+				r := int(br.read(2))
+				sym += r
+				if r != 3 {
+					break
+				}
+			}
+			if sym > maxSym {
+				return nil, 0, 0, ErrCorrupt
+			}
+		}
+		max := 2*threshold - 1 - remaining
+		var count int
+		if low := int(br.peek(nbBits - 1)); low < max {
+			count = low
+			br.pos += nbBits - 1
+		} else {
+			count = int(br.peek(nbBits))
+			if count >= threshold {
+				count -= max
+			}
+			br.pos += nbBits
+		}
+		count--
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		norm[sym] = int16(count)
+		sym++
+		prev0 = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	n := (br.pos + 7) / 8
+	if remaining != 1 || n > len(in) {
+		return nil, 0, 0, ErrCorrupt
+	}
+	return norm[:sym], log, n, nil
+}
+
+func writeNCount(dst []byte, norm []int16, log uint8) []byte {
+	w := bitWriter{out: dst}
+	w.add(uint64(log-5), 4)
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	nbBits := log + 1
+	prev0 := false
+	for sym := 0; sym < len(norm) && remaining > 1; {
+		if prev0 {
+			start := sym
+			for norm[sym] == 0 {
+				sym++
+			}
+			for sym >= start+24 {
+				start += 24
+				w.add(0xFFFF, 16)
+			}
+			for sym >= start+3 {
+				start += 3
+				w.add(3, 2)
+			}
+			w.add(uint64(sym-start), 2)
+		}
+		count := int(norm[sym])
+		sym++
+		max := 2*threshold - 1 - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		count++
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			w.add(uint64(count), nbBits-1)
+		} else {
+			w.add(uint64(count), nbBits)
+		}
+		prev0 = count == 1
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if w.n > 0 {
+		w.out = append(w.out, byte(w.acc))
+	}
+	return w.out
+}
+
+func normalize(counts []int, total int, log uint8) []int16 {
+	size := 1 << log
+	norm := make([]int16, len(counts))
+	sum := 0
+	largest := -1
+	for s, c := range counts {
+		if c == 0 {
+			continue
+		}
+		v := (c*size + total/2) / total
+		if v < 1 {
+			v = 1
+		}
+		norm[s] = int16(v)
+		sum += v
+		if largest < 0 || c > counts[largest] {
+			largest = s
+		}
+	}
+	if sum < size {
+		norm[largest] += int16(size - sum)
+	}
+	for sum > size {
+		best := largest
+		for s := range norm {
+			if norm[s] > norm[best] {
+				best = s
+			}
+		}
+		norm[best]--
+		sum--
+	}
+	return norm
+}
+
+func tableLog(total, distinct int, maxLog uint8) uint8 {
+	log := highBit(uint32(total)) + 1
+	for 1<<log < 2*distinct && log < maxLog {
+		log++
+	}
+	if log < 5 {
+		log = 5
+	}
+	if log > maxLog {
+		log = maxLog
+	}
+	return log
+}
+
+func fseCost(counts []int, norm []int16, log uint8) float64 {
+	cost := 0.0
+	for s, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if s >= len(norm) || norm[s] == 0 {
+			return math.Inf(1)
+		}
+		p := float64(norm[s])
+		if p < 0 {
+			p = 1
+		}
+		cost += float64(c) * (float64(log) - math.Log2(p))
+	}
+	return cost
+}
+
+type fseTransform struct {
+	deltaBits  uint32
+	deltaState int32
+}
+
+type fseEncoder struct {
+	log    uint8
+	states []uint16
+	tt     []fseTransform
+}
+
+func buildFSEEncoder(norm []int16, log uint8) *fseEncoder {
+	size := 1 << log
+	symbols, _ := spread(norm, log)
+	cumul := make([]int, len(norm)+1)
+	for s, n := range norm {
+		if n == -1 {
+			n = 1
+		}
+		cumul[s+1] = cumul[s] + int(n)
+	}
+	e := &fseEncoder{log: log, states: make([]uint16, size), tt: make([]fseTransform, len(norm))}
+	for u, s := range symbols {
+		e.states[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+	total := 0
+	for s, n := range norm {
+		switch n {
+		case 0:
+			e.tt[s].deltaBits = uint32(int(log+1)<<16 - size)
+		case -1, 1:
+			e.tt[s].deltaBits = uint32(int(log)<<16 - size)
+			e.tt[s].deltaState = int32(total - 1)
+			total++
+		default:
+			maxBitsOut := log - highBit(uint32(n-1))
+			minStatePlus := uint32(n) << maxBitsOut
+			e.tt[s].deltaBits = uint32(maxBitsOut)<<16 - minStatePlus
+			e.tt[s].deltaState = int32(total - int(n))
+			total += int(n)
+		}
+	}
+	return e
+}
+
+type fseState struct {
+	value uint32
+	enc   *fseEncoder
+}
+
+func (st *fseState) init(enc *fseEncoder, sym uint8) {
+	tt := enc.tt[sym]
+	nb := (tt.deltaBits + 1<<15) >> 16
+	v := nb<<16 - tt.deltaBits
+	st.enc = enc
+	st.value = uint32(enc.states[int(v>>nb)+int(tt.deltaState)])
+}
+
+func (st *fseState) encode(w *bitWriter, sym uint8) {
+	tt := st.enc.tt[sym]
+	nb := (st.value + tt.deltaBits) >> 16
+	w.add(uint64(st.value), uint8(nb))
+	st.value = uint32(st.enc.states[int(st.value>>nb)+int(tt.deltaState)])
+}
+
+func (st *fseState) flush(w *bitWriter) {
+	w.add(uint64(st.value), st.enc.log)
+}
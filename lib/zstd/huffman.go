@@ -0,0 +1,373 @@
+package zstd
+
+import "sort"
+
+type hufEntry struct {
+	sym  uint8
+	bits uint8
+}
+
+type hufTable struct {
+	log uint8
+	e   []hufEntry
+}
+
+func readWeights(in []byte) ([]uint8, int, error) {
+	if len(in) == 0 {
+		return nil, 0, ErrCorrupt
+	}
+	h := int(in[0])
+	if h >= 128 {
+		nb := h - 127
+		size := (nb + 1) / 2
+		if 1+size > len(in) {
+			return nil, 0, ErrCorrupt
+		}
+		w := make([]uint8, nb)
+		for i := range w {
+			b := in[1+i/2]
+			if i%2 == 0 {
+				w[i] = b >> 4
+			} else {
+				w[i] = b & 15
+			}
+		}
+		return w, 1 + size, nil
+	}
+	if 1+h > len(in) {
+		return nil, 0, ErrCorrupt
+	}
+	w, err := decodeWeights(in[1 : 1+h])
+	return w, 1 + h, err
+}
+
+func decodeWeights(in []byte) ([]uint8, error) {
+	norm, log, n, err := readNCount(in, 255, 6)
+	if err != nil {
+		return nil, err
+	}
+	t, err := buildFSE(norm, log)
+	if err != nil {
+		return nil, err
+	}
+	var br backwardBits
+	if err = br.init(in[n:]); err != nil {
+		return nil, err
+	}
+	s1 := uint16(br.read(log))
+	s2 := uint16(br.read(log))
+	var w []uint8
+	for len(w) < 255 {
+		e := t.e[s1]
+		w = append(w, e.sym)
+		s1 = e.base + uint16(br.read(e.bits))
+		if br.left < 0 {
+			w = append(w, t.e[s2].sym)
+			return w, nil
+		}
+		e = t.e[s2]
+		w = append(w, e.sym)
+		s2 = e.base + uint16(br.read(e.bits))
+		if br.left < 0 {
+			w = append(w, t.e[s1].sym)
+			return w, nil
+		}
+	}
+	return nil, ErrCorrupt
+}
+
+func readHuffman(in []byte) (*hufTable, int, error) {
+	w, n, err := readWeights(in)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(w) > 255 {
+		return nil, 0, ErrCorrupt
+	}
+	total := uint32(0)
+	for _, v := range w {
+		if v > maxHufBits {
+			return nil, 0, ErrCorrupt
+		}
+		if v > 0 {
+			total += 1 << (v - 1)
+		}
+	}
+	if total == 0 {
+		return nil, 0, ErrCorrupt
+	}
+	log := highBit(total) + 1
+	if log > maxHufBits {
+		return nil, 0, ErrCorrupt
+	}
+	rest := uint32(1)<<log - total
+	if rest&(rest-1) != 0 {
+		return nil, 0, ErrCorrupt
+	}
+	w = append(w, highBit(rest)+1)
+
+	var rank [maxHufBits + 2]uint32
+	for _, v := range w {
+		rank[v]++
+	}
+	next := uint32(0)
+	for v := 1; v <= int(log); v++ {
+		cur := next
+		next += rank[v] << (v - 1)
+		rank[v] = cur
+	}
+	t := &hufTable{log: log, e: make([]hufEntry, 1<<log)}
+	for s, v := range w {
+		if v == 0 {
+			continue
+		}
+		length := uint32(1) << (v - 1)
+		e := hufEntry{sym: uint8(s), bits: log + 1 - v}
+		for u := rank[v]; u < rank[v]+length; u++ {
+			t.e[u] = e
+		}
+		rank[v] += length
+	}
+	return t, n, nil
+}
+
+func (t *hufTable) decode(dst, in []byte) error {
+	var br backwardBits
+	if err := br.init(in); err != nil {
+		return err
+	}
+	for i := range dst {
+		e := t.e[br.peek(t.log)]
+		dst[i] = e.sym
+		br.skip(e.bits)
+	}
+	if br.left != 0 {
+		return ErrCorrupt
+	}
+	return nil
+}
+
+type hufCode struct {
+	code uint16
+	bits uint8
+}
+
+func hufLengths(counts []int, maxBits uint8) []uint8 {
+	type node struct {
+		weight int
+		left   int
+		right  int
+	}
+	for {
+		var nodes []node
+		var leaves []int
+		for s, c := range counts {
+			if c > 0 {
+				nodes = append(nodes, node{weight: c, left: -1, right: s})
+				leaves = append(leaves, len(nodes)-1)
+			}
+		}
+		sort.SliceStable(leaves, func(i, j int) bool { return nodes[leaves[i]].weight < nodes[leaves[j]].weight })
+		var merged []int
+		pop := func() int {
+			if len(merged) == 0 || (len(leaves) > 0 && nodes[leaves[0]].weight <= nodes[merged[0]].weight) {
+				n := leaves[0]
+				leaves = leaves[1:]
+				return n
+			}
+			n := merged[0]
+			merged = merged[1:]
+			return n
+		}
+		for len(leaves)+len(merged) > 1 {
+			a, b := pop(), pop()
+			nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, left: a, right: b})
+			merged = append(merged, len(nodes)-1)
+		}
+		lengths := make([]uint8, len(counts))
+		var walk func(n int, depth uint8)
+		walk = func(n int, depth uint8) {
+			if nodes[n].left < 0 {
+				lengths[nodes[n].right] = depth
+				return
+			}
+			walk(nodes[n].left, depth+1)
+			walk(nodes[n].right, depth+1)
+		}
+		walk(len(nodes)-1, 0)
+		max := uint8(0)
+		for _, l := range lengths {
+			if l > max {
+				max = l
+			}
+		}
+		if max <= maxBits {
+			return lengths
+		}
+		scaled := make([]int, len(counts))
+		for s, c := range counts {
+			if c > 0 {
+				scaled[s] = (c + 1) / 2
+			}
+		}
+		counts = scaled
+	}
+}
+
+func hufCodes(weights []uint8, log uint8) []hufCode {
+	var rank [maxHufBits + 2]uint32
+	for _, v := range weights {
+		rank[v]++
+	}
+	next := uint32(0)
+	for v := 1; v <= int(log); v++ {
+		cur := next
+		next += rank[v] << (v - 1)
+		rank[v] = cur
+	}
+	codes := make([]hufCode, len(weights))
+	for s, v := range weights {
+		if v == 0 {
+			continue
+		}
+		codes[s] = hufCode{code: uint16(rank[v] >> (v - 1)), bits: log + 1 - v}
+		rank[v] += 1 << (v - 1)
+	}
+	return codes
+}
+
+func encodeWeights(dst []byte, w []uint8) []byte {
+	counts := make([]int, maxHufBits+1)
+	distinct := 0
+	for _, v := range w {
+		if counts[v] == 0 {
+			distinct++
+		}
+		counts[v]++
+	}
+	var fse []byte
+	if distinct > 1 && len(w) > 1 {
+		log := tableLog(len(w), distinct, 6)
+		norm := normalize(counts, len(w), log)
+		last := len(norm) - 1
+		for norm[last] == 0 {
+			last--
+		}
+		norm = norm[:last+1]
+		enc := buildFSEEncoder(norm, log)
+		fse = writeNCount(nil, norm, log)
+		var bw bitWriter
+		var s1, s2 fseState
+		i := len(w)
+		if i%2 == 1 {
+			s1.init(enc, w[i-1])
+			s2.init(enc, w[i-2])
+			s1.encode(&bw, w[i-3])
+			i -= 3
+		} else {
+			s2.init(enc, w[i-1])
+			s1.init(enc, w[i-2])
+			i -= 2
+		}
+		for i > 0 {
+			s2.encode(&bw, w[i-1])
+			s1.encode(&bw, w[i-2])
+			i -= 2
+		}
+		s2.flush(&bw)
+		s1.flush(&bw)
+		fse = append(fse, bw.close()...)
+		if got, err := decodeWeights(fse); err != nil || string(got) != string(w) || len(fse) >= 128 {
+			fse = nil
+		}
+	}
+	direct := len(w) <= 128
+	if fse != nil && (!direct || len(fse) < (len(w)+1)/2) {
+		dst = append(dst, byte(len(fse)))
+		return append(dst, fse...)
+	}
+	if !direct {
+		return nil
+	}
+	dst = append(dst, byte(127+len(w)))
+	for i := 0; i < len(w); i += 2 {
+		b := w[i] << 4
+		if i+1 < len(w) {
+			b |= w[i+1]
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+func encodeStream(dst, src []byte, codes []hufCode) []byte {
+	bw := bitWriter{out: dst}
+	for i := len(src) - 1; i >= 0; i-- {
+		c := codes[src[i]]
+		bw.add(uint64(c.code), c.bits)
+	}
+	return bw.close()
+}
+
+func compressLiterals(lits []byte) ([]byte, bool) {
+	counts := make([]int, 256)
+	maxSym := 0
+	distinct := 0
+	for _, b := range lits {
+		if counts[b] == 0 {
+			distinct++
+		}
+		counts[b]++
+		if int(b) > maxSym {
+			maxSym = int(b)
+		}
+	}
+	if distinct < 2 {
+		return nil, false
+	}
+	lengths := hufLengths(counts[:maxSym+1], maxHufBits)
+	log := uint8(0)
+	for _, l := range lengths {
+		if l > log {
+			log = l
+		}
+	}
+	weights := make([]uint8, maxSym+1)
+	for s, l := range lengths {
+		if l > 0 {
+			weights[s] = log + 1 - l
+		}
+	}
+	out := encodeWeights(nil, weights[:maxSym])
+	if out == nil {
+		return nil, false
+	}
+	codes := hufCodes(weights, log)
+	single := len(lits) < 1024
+	if single {
+		out = encodeStream(out, lits, codes)
+	} else {
+		seg := (len(lits) + 3) / 4
+		table := len(out)
+		out = append(out, 0, 0, 0, 0, 0, 0)
+		start := len(out)
+		for i := 0; i < 4; i++ {
+			lo := i * seg
+			hi := lo + seg
+			if hi > len(lits) {
+				hi = len(lits)
+			}
+			out = encodeStream(out, lits[lo:hi], codes)
+			if i < 3 {
+				size := len(out) - start
+				out[table+2*i] = byte(size)
+				out[table+2*i+1] = byte(size >> 8)
+				start = len(out)
+				if size > 0xFFFF {
+					return nil, false
+				}
+			}
+		}
+	}
+	return out, single
+}
@@ -0,0 +1,98 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+type xxh64 struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int
+}
+
+func (h *xxh64) reset() {
+	h.v = [4]uint64{prime1, prime2, 0, 0}
+	h.v[0] += prime2
+	h.v[3] -= prime1
+	h.total = 0
+	h.n = 0
+}
+
+func round(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime1
+}
+
+func mergeRound(acc, val uint64) uint64 {
+	acc ^= round(0, val)
+	return acc*prime1 + prime4
+}
+
+func (h *xxh64) stripe(b []byte) {
+	for i := range h.v {
+		h.v[i] = round(h.v[i], binary.LittleEndian.Uint64(b[8*i:]))
+	}
+}
+
+func (h *xxh64) Write(b []byte) {
+	h.total += uint64(len(b))
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], b)
+		h.n += c
+		b = b[c:]
+		if h.n < 32 {
+			return
+		}
+		h.stripe(h.mem[:])
+		h.n = 0
+	}
+	for ; len(b) >= 32; b = b[32:] {
+		h.stripe(b)
+	}
+	h.n = copy(h.mem[:], b)
+}
+
+func (h *xxh64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = mergeRound(acc, v)
+		}
+	} else {
+		acc = prime5
+	}
+	acc += h.total
+	b := h.mem[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		acc ^= round(0, binary.LittleEndian.Uint64(b))
+		acc = bits.RotateLeft64(acc, 27)*prime1 + prime4
+	}
+	if len(b) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(b)) * prime1
+		acc = bits.RotateLeft64(acc, 23)*prime2 + prime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		acc ^= uint64(c) * prime5
+		acc = bits.RotateLeft64(acc, 11) * prime1
+	}
+	acc ^= acc >> 33
+	acc *= prime2
+	acc ^= acc >> 29
+	acc *= prime3
+	acc ^= acc >> 32
+	return acc
+}
@@ -0,0 +1,238 @@
+// Package zstd is a pure Go Zstandard encoder and decoder. Sources generated
+// with -codec zstd import it as their runtime decoder.
+package zstd
+
+import (
+	"errors"
+	"math/bits"
+)
+
+const (
+	magic         = 0xFD2FB528
+	skippableMask = 0xFFFFFFF0
+	skippableId   = 0x184D2A50
+	maxBlockSize  = 128 << 10
+	maxWindowLog  = 27
+	minWindowLog  = 10
+)
+
+var (
+	ErrMagic    = errors.New("zstd: invalid magic number")
+	ErrCorrupt  = errors.New("zstd: corrupt input")
+	ErrChecksum = errors.New("zstd: checksum mismatch")
+	ErrWindow   = errors.New("zstd: window size too large")
+	ErrClosed   = errors.New("zstd: reader is closed")
+)
+
+const (
+	blockRaw = iota
+	blockRLE
+	blockCompressed
+	blockReserved
+)
+
+const (
+	litRaw = iota
+	litRLE
+	litCompressed
+	litTreeless
+)
+
+const (
+	modePredefined = iota
+	modeRLE
+	modeCompressed
+	modeRepeat
+)
+
+const (
+	maxLLSymbol = 35
+	maxMLSymbol = 52
+	maxOFSymbol = 31
+	maxLLLog    = 9
+	maxMLLog    = 9
+	maxOFLog    = 8
+	maxHufBits  = 11
+)
+
+var llBase = [maxLLSymbol + 1]uint32{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+	8192, 16384, 32768, 65536,
+}
+
+var llBits = [maxLLSymbol + 1]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+	13, 14, 15, 16,
+}
+
+var mlBase = [maxMLSymbol + 1]uint32{
+	3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+	19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+	35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+	4099, 8195, 16387, 32771, 65539,
+}
+
+var mlBits = [maxMLSymbol + 1]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+	12, 13, 14, 15, 16,
+}
+
+var llDefault = []int16{
+	4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+	-1, -1, -1, -1,
+}
+
+var mlDefault = []int16{
+	1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+	-1, -1, -1, -1, -1,
+}
+
+var ofDefault = []int16{
+	1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+}
+
+const (
+	llDefaultLog = 6
+	mlDefaultLog = 6
+	ofDefaultLog = 5
+)
+
+func highBit(v uint32) uint8 {
+	return uint8(bits.Len32(v) - 1)
+}
+
+func llCode(ll uint32) uint8 {
+	if ll < 16 {
+		return uint8(ll)
+	}
+	if ll >= 64 {
+		return highBit(ll) + 19
+	}
+	c := uint8(24)
+	for llBase[c] > ll {
+		c--
+	}
+	return c
+}
+
+func mlCode(ml uint32) uint8 {
+	if ml < 35 {
+		return uint8(ml - 3)
+	}
+	if ml >= 131 {
+		return highBit(ml-3) + 36
+	}
+	c := uint8(42)
+	for mlBase[c] > ml {
+		c--
+	}
+	return c
+}
+
+type backwardBits struct {
+	in    []byte
+	off   int
+	value uint64
+	n     int
+	left  int
+}
+
+func (b *backwardBits) init(in []byte) error {
+	if len(in) == 0 || in[len(in)-1] == 0 {
+		return ErrCorrupt
+	}
+	last := in[len(in)-1]
+	hb := int(highBit(uint32(last)))
+	b.in = in
+	b.off = len(in) - 1
+	b.value = uint64(last) & (1<<hb - 1)
+	b.n = hb
+	b.left = b.off*8 + hb
+	return nil
+}
+
+func (b *backwardBits) fill() {
+	for b.n <= 56 && b.off > 0 {
+		b.off--
+		b.value = b.value<<8 | uint64(b.in[b.off])
+		b.n += 8
+	}
+}
+
+func (b *backwardBits) peek(k uint8) uint64 {
+	if b.n < int(k) {
+		b.fill()
+	}
+	mask := uint64(1)<<k - 1
+	if b.n >= int(k) {
+		return (b.value >> (b.n - int(k))) & mask
+	}
+	return (b.value << (int(k) - b.n)) & mask
+}
+
+func (b *backwardBits) skip(k uint8) {
+	b.left -= int(k)
+	b.n -= int(k)
+	if b.n < 0 {
+		b.n = 0
+	}
+}
+
+func (b *backwardBits) read(k uint8) uint64 {
+	v := b.peek(k)
+	b.skip(k)
+	return v
+}
+
+type forwardBits struct {
+	in  []byte
+	pos int
+}
+
+func (b *forwardBits) peek(k int) uint32 {
+	var v uint64
+	i := b.pos >> 3
+	for j := 0; j < 5 && i+j < len(b.in); j++ {
+		v |= uint64(b.in[i+j]) << (8 * j)
+	}
+	return uint32(v>>(b.pos&7)) & (1<<k - 1)
+}
+
+func (b *forwardBits) read(k int) uint32 {
+	v := b.peek(k)
+	b.pos += k
+	return v
+}
+
+type bitWriter struct {
+	out []byte
+	acc uint64
+	n   uint8
+}
+
+func (w *bitWriter) add(v uint64, k uint8) {
+	w.acc |= (v & (1<<k - 1)) << w.n
+	w.n += k
+	for w.n >= 8 {
+		w.out = append(w.out, byte(w.acc))
+		w.acc >>= 8
+		w.n -= 8
+	}
+}
+
+func (w *bitWriter) close() []byte {
+	w.add(1, 1)
+	if w.n > 0 {
+		w.out = append(w.out, byte(w.acc))
+	}
+	w.acc, w.n = 0, 0
+	return w.out
+}
@@ -0,0 +1,177 @@
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func levels() []int {
+	l := []int{HuffmanOnly, NoCompression}
+	for i := MinLevel; i <= MaxLevel; i++ {
+		l = append(l, i)
+	}
+	return l
+}
+
+func samples() map[string][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 64<<10)
+	rnd.Read(random)
+	text := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 5000))
+	mixed := make([]byte, 0, 300<<10)
+	for len(mixed) < 300<<10 {
+		n := rnd.Intn(2000)
+		if rnd.Intn(2) == 0 {
+			mixed = append(mixed, text[:n]...)
+		} else {
+			mixed = append(mixed, random[:n]...)
+		}
+	}
+	return map[string][]byte{
+		"empty":  {},
+		"byte":   {'x'},
+		"rle":    bytes.Repeat([]byte{0}, 200<<10),
+		"random": random,
+		"text":   text,
+		"mixed":  mixed,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, data := range samples() {
+		for _, level := range levels() {
+			enc := Encode(data, nil, level)
+			dec, err := Decode(enc, nil)
+			if err != nil {
+				t.Fatalf("%s level %d: %v", name, level, err)
+			}
+			if !bytes.Equal(dec, data) {
+				t.Fatalf("%s level %d: round trip mismatch", name, level)
+			}
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	data := samples()
+	var numbers []byte
+	for i := 1; i <= 30000; i++ {
+		numbers = strconv.AppendInt(numbers, int64(i), 10)
+		numbers = append(numbers, '\n')
+	}
+	data["numbers"] = numbers
+	for name, data := range data {
+		prev := len(Encode(data, nil, MinLevel))
+		for level := MinLevel + 1; level <= MaxLevel; level++ {
+			n := len(Encode(data, nil, level))
+			if n > prev {
+				t.Fatalf("%s: level %d is %d bytes, level %d was %d", name, level, n, level-1, prev)
+			}
+			prev = n
+		}
+	}
+}
+
+func TestRoundTripDict(t *testing.T) {
+	dict := []byte(strings.Repeat("alpha bravo charlie delta echo foxtrot\n", 20))
+	data := []byte(strings.Repeat("charlie delta echo foxtrot golf alpha\n", 3))
+	for _, level := range levels() {
+		enc := Encode(data, dict, level)
+		dec, err := Decode(enc, dict)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if !bytes.Equal(dec, data) {
+			t.Fatalf("level %d: round trip mismatch", level)
+		}
+	}
+	if plain := Encode(data, nil, DefaultLevel); len(Encode(data, dict, DefaultLevel)) >= len(plain) {
+		t.Fatal("dictionary did not improve compression")
+	}
+}
+
+func TestReader(t *testing.T) {
+	data := samples()["mixed"]
+	r, err := NewReader(bytes.NewReader(Encode(data, nil, DefaultLevel)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	buf := make([]byte, 777)
+	for {
+		n, err := r.Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("round trip mismatch")
+	}
+
+	r, err = NewReader(bytes.NewReader(Encode(data, nil, DefaultLevel)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(buf); err != ErrClosed {
+		t.Fatalf("read after close: got %v, want %v", err, ErrClosed)
+	}
+}
+
+// testdata/ref.txt.zst was produced by the reference CLI with
+// "zstd -19 --check testdata/ref.txt -o testdata/ref.txt.zst".
+func TestReference(t *testing.T) {
+	want, err := os.ReadFile("testdata/ref.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := os.ReadFile("testdata/ref.txt.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(frame, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("reference frame decoded incorrectly")
+	}
+
+	frame[len(frame)-1] ^= 1
+	if _, err := Decode(frame, nil); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("bad checksum: got %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	if _, err := Decode([]byte("not a zstd frame"), nil); err != ErrMagic {
+		t.Fatalf("bad magic: got %v, want %v", err, ErrMagic)
+	}
+	enc := Encode(samples()["text"], nil, DefaultLevel)
+	if _, err := Decode(enc[:len(enc)/2], nil); err == nil {
+		t.Fatal("truncated frame decoded without error")
+	}
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 2000; i++ {
+		mut := append([]byte(nil), enc...)
+		for j := 0; j < 1+rnd.Intn(4); j++ {
+			mut[4+rnd.Intn(len(mut)-4)] = byte(rnd.Intn(256))
+		}
+		Decode(mut, nil)
+	}
+}
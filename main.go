@@ -1,26 +1,107 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
 	"github.com/lecuong04/compressembed/lib"
 	"github.com/lecuong04/compressembed/lib/flag"
 )
 
 var cfg = lib.Config{
-	Pkg:    "main",
-	Func:   lib.StrGen(6),
-	Input:  "",
-	Key:    lib.KeyGen(),
-	Output: "resource.dat",
-	Var:    "",
-	Src:    "compressed.go",
+	Pkg:       "main",
+	Input:     "",
+	Output:    "resource.dat",
+	Var:       "",
+	Src:       "compressed.go",
+	Codec:     "zlib",
+	Level:     lib.DefaultLevel,
+	Policy:    lib.PolicySmallest,
+	KeySource: lib.KeyLiteral,
+	BlockSize: lib.DefaultBlockSize,
+	DevEnv:    lib.DefaultDevEnv,
+	SetKey:    lib.DefaultSetKey,
+}
+
+var verify bool
+
+func trainDict(args []string) {
+	fs := flag.NewFlagSet("train-dict", flag.ExitOnError)
+	out := fs.String("out", "dict.bin", "Dictionary file to create")
+	size := fs.Int("size", lib.DefaultDictSize, "Maximum dictionary size in bytes")
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		log.Fatal("Usage: train-dict [-out FILE] [-size N] SAMPLE...")
+	}
+
+	samples, err := lib.ReadSamples(fs.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(samples) == 0 {
+		log.Fatal("No sample files found")
+	}
+	dict := lib.TrainDict(samples, *size)
+	if err := os.WriteFile(*out, dict, 0o644); err != nil {
+		log.Fatal("Cannot create file")
+	}
+	fmt.Printf("Trained %d byte dictionary from %d samples\n", len(dict), len(samples))
 }
 
 func main() {
-	flag.StringVar(&cfg.Input, "in", cfg.Input, "Input file (Require)")
+	if len(os.Args) > 1 && os.Args[1] == "train-dict" {
+		trainDict(os.Args[2:])
+		return
+	}
+	flag.StringVar(&cfg.Input, "in", cfg.Input, "Input file, directory or glob pattern (Require without -embed or -manifest)")
 	flag.StringVar(&cfg.Output, "out", cfg.Output, "Compressed output file")
 	flag.StringVar(&cfg.Src, "src", cfg.Src, "Source file name to create")
 	flag.StringVar(&cfg.Pkg, "pkg", cfg.Pkg, "Name of package for source file to output")
-	flag.StringVar(&cfg.Var, "var", cfg.Var, "Variable name for decompressed resource (Require)")
+	flag.StringVar(&cfg.Var, "var", cfg.Var, "Variable name for decompressed resource (Require with -in)")
+	flag.StringVar(&cfg.Codec, "codec", cfg.Codec, "Compression codec ("+strings.Join(append(lib.CodecNames(), lib.AutoCodec), ", ")+"); gzip also generates NAMEGzip() returning the gzip bytes unless encrypted or signed")
+	flag.Func("level", "Compression level: default, none, huffman or a codec specific number", func(s string) (err error) {
+		cfg.Level, err = lib.ParseLevel(s)
+		return err
+	})
+	flag.StringVar(&cfg.Policy, "policy", cfg.Policy, "Selection policy for -codec auto ("+strings.Join(lib.Policies, ", ")+")")
+	flag.BoolVar(&cfg.Encrypt, "encrypt", cfg.Encrypt, "Encrypt compressed output with AES-256-GCM")
+	flag.StringVar(&cfg.Key, "key", cfg.Key, "Key used as dictionary and for encryption (Random with the literal key source)")
+	flag.StringVar(&cfg.KeySource, "key-source", cfg.KeySource, "Where generated code gets the key: literal, env:NAME, file:PATH or func")
+	flag.StringVar(&cfg.SetKey, "set-key", cfg.SetKey, "Name of the setter generated for -key-source func, distinct per file sharing a package")
+	flag.BoolVar(&cfg.Obfuscate, "obfuscate", cfg.Obfuscate, "Split the literal key into masked shards in generated source")
+	flag.StringVar(&cfg.SignKey, "sign-key", cfg.SignKey, "Ed25519 private key file (PEM PKCS #8 or hex) used to sign the output")
+	flag.Func("embed", "Additional resource as in=PATH,var=NAME[,out=FILE][,tags=CONSTRAINT] (Repeatable)", func(s string) error {
+		e, err := lib.ParseEmbed(s)
+		cfg.Embeds = append(cfg.Embeds, e)
+		return err
+	})
+	flag.Func("manifest", "File listing one in=PATH,var=NAME[,out=FILE][,tags=CONSTRAINT] resource per line", func(s string) error {
+		embeds, err := lib.ReadManifest(s)
+		cfg.Embeds = append(cfg.Embeds, embeds...)
+		return err
+	})
+	flag.BoolVar(&cfg.Split, "split", cfg.Split, "Write each resource to its own NAME.dat instead of sharing -out")
+	flag.BoolVar(&cfg.Solid, "solid", cfg.Solid, "Compress directory and glob inputs in solid blocks instead of per file")
+	flag.IntVar(&cfg.BlockSize, "block-size", cfg.BlockSize, "Uncompressed size of each solid block in bytes")
+	flag.StringVar(&cfg.Dict, "dict", cfg.Dict, "Preset dictionary from train-dict used instead of the key")
+	flag.BoolVar(&cfg.Lazy, "lazy", cfg.Lazy, "Decode resources on first use through accessor functions instead of in init")
+	flag.StringVar(&cfg.GoVersion, "go", cfg.GoVersion, "Go version targeted by generated code (default from go.mod)")
+	flag.StringVar(&cfg.Stream, "stream", cfg.Stream, "Generate OpenNAME() io.ReadCloser accessors: alongside or only (instead of eager variables); lz4 does not stream")
+	flag.BoolVar(&cfg.Errors, "errors", cfg.Errors, "Generate NAME() (value, error) and MustNAME() accessors instead of variables")
+	flag.BoolVar(&cfg.Inline, "inline", cfg.Inline, "Write compressed data into the source as string literals instead of -out files")
+	flag.BoolVar(&cfg.Dev, "dev", cfg.Dev, "Also generate a dev build file that reads inputs from disk instead of the embedded data")
+	flag.StringVar(&cfg.DevEnv, "dev-env", cfg.DevEnv, "Environment variable overriding the module root that dev builds read inputs from")
+	flag.StringVar(&cfg.Template, "template", cfg.Template, "Custom text/template for the source file; {{template \"compressembed\" .}} renders the built-in one (data and funcs are listed in lib/template.tmpl)")
+	flag.BoolVar(&verify, "verify", verify, "Check that -out and -src still match -in instead of generating them")
 	flag.Parse()
+	if verify {
+		if err := lib.Verify(cfg); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("OK")
+		return
+	}
 	lib.Run(cfg)
 }